@@ -31,10 +31,12 @@
 package j2x
 
 import (
-	"encoding/json"
+	"bytes"
 	"encoding/xml"
-	"errors"
 	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
 )
 
 const (
@@ -44,6 +46,7 @@ const (
 var useGoXmlEmptyElemSyntax bool
 
 // UseGoXmlEmptyElemSyntax() - <tag ...></tag> rather than <tag .../>
+// The setting applies to the package-level functions; Encoders have their own setting.
 func UseGoXmlEmptyElemSyntax() {
 	useGoXmlEmptyElemSyntax = true
 }
@@ -54,11 +57,12 @@ func UseJ2xEmptyElemSyntax() {
 }
 
 // Extends xml.Marshal() to handle JSON and map[string]interface{} types.
+//
 //	This is the inverse of x2j.Unmarshal().
 //	Strings are interpreted as JSON strings; use xml.Marshal() to marshal
 //	a string as "<string>...</string>" - the standard package handling.
-//	Follows xml.Marshal handling of types except for string and map[string]interface{}
-//	values. For more generalized marshal'ing use MapToXml().
+//	Follows xml.Marshal handling of types except for string, map[string]interface{} and
+//	*OrderedMap values. For more generalized marshal'ing use MapToXml().
 //	See MapToXml() for encoding rules.
 func Marshal(v interface{}, rootTag ...string) ([]byte, error) {
 	switch v.(type) {
@@ -68,138 +72,354 @@ func Marshal(v interface{}, rootTag ...string) ([]byte, error) {
 	case map[string]interface{}:
 		xmlString, err := MapToXml(v.(map[string]interface{}), rootTag...)
 		return xmlString, err
+	case *OrderedMap:
+		xmlString, err := OrderedMapToXml(v.(*OrderedMap), rootTag...)
+		return xmlString, err
 	}
 	return xml.Marshal(v)
 }
 
 // Encode JSON value as XML.  The inverse of x2j.DocToJson().
+//
 //	See MapToXml() for encoding rules.
+//	A JSON array is encoded as the root element with each value as an <item> element -
+//	`[ 1, 2 ]` encodes as `<doc><item>1</item><item>2</item></doc>` - and any other
+//	non-object value as the root element value - `"hello"` encodes as `<doc>hello</doc>`.
 func JsonToXml(jsonString []byte, rootTag ...string) ([]byte, error) {
-	m := make(map[string]interface{}, 0)
-	if err := json.Unmarshal(jsonString, &m); err != nil {
-		return nil, err
-	}
-	return MapToXml(m, rootTag...)
+	var b bytes.Buffer
+	err := defaultEncoder(&b, rootTag).EncodeJson(jsonString)
+	return b.Bytes(), err
 }
 
 // Encode a map[string]interface{} variable as XML.  The inverse of x2j.DocToMap().
 // The following rules apply.
-//    - The key label "#text" is treated as the value for a simple element with attributes.
-//    - Map keys that begin with a hyphen, '-', are interpreted as attributes.
-//      It is an error if the attribute doesn't have a []byte, string, number, or boolean value.
-//    - Map value type encoding:
-//          > string, bool, float64, int, int32, int64, float32: per "%v" formating
-//          > []bool, []uint8: by casting to string
-//          > structures, etc.: handed to xml.Marshal() - if there is an error, the element
-//            value is "UNKNOWN"
-//    - Elements with only attribute values or are null are terminated using "/>".
-//    - If len(m) == 1 and no rootTag is provided, then the map key is used as the root tag.
-//      Thus, `{ "key":"value" }` encodes as `<key>value</key>`.
+//   - The key label "#text" is treated as the value for a simple element with attributes.
+//   - The value of the key label "#cdata" is encoded as a CDATA section, like a "#text" value.
+//     See CData() for encoding other text as CDATA sections.
+//   - The values of the key labels "#comment", "?target" and "!DOCTYPE" are encoded in place as
+//     a comment, a processing instruction - "?xml-stylesheet" - and a DOCTYPE declaration; for
+//     a list value each item is encoded.  In a top-level map they are written before the root
+//     element - after it if they follow the key of the root element in key order - and are
+//     not considered in selecting the root tag.  A single DOCTYPE declaration is only allowed
+//     before the root element, whose tag it must name, and a comment must not contain "--".
+//   - The value of the key label "#content" is encoded as mixed content: a list of text values
+//     and maps whose keys are encoded as child elements, in list order - {"p":{"#content":
+//     ["Hello ",{"b":"world"},"!"]}} encodes as <p>Hello <b>world</b>!</p>.  Like "#text" it
+//     may only occur with attribute keys.  Mixed content is not indented.  See ContentKey().
+//   - Map keys that begin with a hyphen, '-', are interpreted as attributes.
+//     An Encoder can use another attribute prefix and text key; see AttrPrefix() and TextKey().
+//     It is an error if the attribute doesn't have a []byte, string, number, or boolean value.
+//   - Character data and attribute values are escaped: &, <, >, ' and " are replaced by
+//     entity references and characters that are not legal in XML are replaced by U+FFFD.
+//   - Map value type encoding: string, bool and numeric values - all int, uint, float and
+//     complex types, json.Number, *big.Int and *big.Float - time.Time, encoding.TextMarshaler
+//     and fmt.Stringer values are encoded as text, for elements and attributes alike; []byte
+//     values by casting to string; structures, etc. are handed to xml.Marshal() - if there
+//     is an error, an *EncodeError with Kind ErrMarshal is returned; see Unencodable() for
+//     other policies.  JSON numbers are decoded as json.Number values, so they keep their
+//     precision.  See FloatFormat() and TimeLayout().
+//   - Namespace prefixes of element and attribute keys, "soap:Envelope", must be declared
+//     in scope with "-xmlns:prefix" attributes.  Clark-notation keys, "{http://ns}local", are
+//     encoded with a prefix bound to the URI.  See Namespaces() and NamespaceCheck().
+//   - List values are encoded as repeated elements with the list's key as the tag.  A list
+//     in a list is encoded as one element with an <item> element for each value.  See
+//     WrapArrays() for encoding lists as a container element.
+//   - Keys that are not valid XML names are an error.  See Names() for other policies.
+//   - Errors are returned as *EncodeError values that locate the value that can't be encoded.
+//   - Attributes and child elements are encoded in sorted key order.  See Ordering() to
+//     keep the key order of the source JSON value.
+//   - Elements with only attribute values or are null are terminated using "/>".
+//   - If len(m) == 1 and no rootTag is provided, then the map key is used as the root tag.
+//     Thus, `{ "key":"value" }` encodes as `<key>value</key>`.
+//
+// MapToXml uses an Encoder with the package settings; use NewEncoder() for per-instance settings.
 func MapToXml(m map[string]interface{}, rootTag ...string) ([]byte, error) {
-	var err error
-	s := new(string)
-
-	if len(m) == 1 && len(rootTag) == 0 {
-		for key, value := range m {
-			if _, ok := value.([]interface{}); ok {
-				err = mapToXml(s, DefaultRootTag, m)
-			} else {
-				err = mapToXml(s, key, value)
-			}
-		}
-	} else if len(rootTag) == 1 {
-		err = mapToXml(s, rootTag[0], m)
-	} else {
-		err = mapToXml(s, DefaultRootTag, m)
-	}
-	return []byte(*s), err
+	var b bytes.Buffer
+	err := defaultEncoder(&b, rootTag).Encode(m)
+	return b.Bytes(), err
 }
 
 // where the work actually happens
 // returns an error if an attribute is not atomic
-func mapToXml(s *string, key string, value interface{}) error {
-	var endTag bool
+// pad is the indentation of the element when encoding pretty XML
+// ns holds the namespace declarations of the enclosing elements
+// path locates value in the map for errors
+func (e *Encoder) mapToXml(w xmlWriter, key string, value interface{}, pad string, ns *nsScope, path *mapPath) error {
+	var endTag, isSimple bool
 
-	if _, ok := value.([]interface{}); !ok {
-		*s += `<` + key
+	if list, ok := value.([]interface{}); ok {
+		if e.itemNamer != nil {
+			return e.mapToXml(w, key, e.newItemList(key, list), pad, ns, path)
+		}
+		for i, v := range list {
+			// a list in a list is encoded as an element with item elements
+			if inner, ok := v.([]interface{}); ok {
+				v = e.newItemList(key, inner)
+			}
+			if err := e.mapToXml(w, key, v, pad, ns, &mapPath{path, "", i}); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
+
+	// the Unencodable() policy applies to values that xml.Marshal() can't encode
+	var marshaled []byte
+	var isMarshaled bool
 	switch value.(type) {
-	case map[string]interface{}:
-		vv := value.(map[string]interface{})
-		lenvv := len(vv)
-		// scan out attributes - keys have prepended hyphen, '-'
-		var cntAttr int
-		for k, v := range vv {
-			if k[:1] == "-" {
-				switch v.(type) {
-				case string, float64, bool, int, int32, int64, float32:
-					*s += ` ` + k[1:] + `="` + fmt.Sprintf("%v", v) + `"`
-					cntAttr++
-				case []byte:		// allow standard xml pkg []byte transform, as below
-					*s += ` ` + k[1:] + `="` + fmt.Sprintf("%v",string(v.([]byte))) + `"`
-					cntAttr++
-				default:
-					return errors.New("invalid attribute value for: " + k)
+	case map[string]interface{}, *OrderedMap, itemList, nil:
+	default:
+		if _, ok := e.formatValue(value); !ok {
+			var err error
+			if e.pretty {
+				marshaled, err = xml.MarshalIndent(value, pad+e.indent, e.indent)
+			} else {
+				marshaled, err = xml.Marshal(value)
+			}
+			isMarshaled = err == nil
+			if err != nil {
+				var skip bool
+				if value, skip, err = e.unencodable(value, err, path); err != nil || skip {
+					return err
 				}
 			}
 		}
+	}
+
+	// namespace declarations of the element are in scope for its name and attributes
+	vv, keys := keysOf(value)
+	scope := &nsScope{parent: ns}
+	for _, k := range keys {
+		if e.isAttr(k) && isNsDecl(k[len(e.attrPrefix):]) {
+			if uri, ok := vv[k].(string); ok {
+				scope.bind(nsDeclPrefix(k[len(e.attrPrefix):]), uri)
+			}
+		}
+	}
+	name, keyAttr, err := e.validName(key, false, path)
+	if err != nil {
+		return err
+	}
+	var decls string
+	tag, err := e.qualify(name, scope, false, &decls, path)
+	if err != nil {
+		return err
+	}
+
+	// scan out attributes - keys have prepended hyphen, '-'
+	var attrNames []string
+	var cntAttr int
+	seen := e.newAttrSet(keyAttr)
+	for _, k := range keys {
+		v := vv[k]
+		if !e.isAttr(k) {
+			continue
+		}
+		if _, ok := e.formatValue(v); !ok {
+			return encodeError(ErrAttrValue, &mapPath{path, k, -1}, fmt.Sprintf("%T", v))
+		}
+		name := k[len(e.attrPrefix):]
+		if !isNsDecl(name) {
+			if name, _, err = e.validName(name, true, &mapPath{path, k, -1}); err != nil {
+				return err
+			}
+			if name, err = e.qualify(name, scope, true, &decls, &mapPath{path, k, -1}); err != nil {
+				return err
+			}
+		}
+		if err = seen.add(name, &mapPath{path, k, -1}); err != nil {
+			return err
+		}
+		attrNames = append(attrNames, name)
+		cntAttr++
+	}
+	w.WriteString(pad)
+	w.WriteByte('<')
+	w.WriteString(tag)
+	w.WriteString(decls)
+	w.WriteString(keyAttr)
+	if cntAttr > 0 {
+		var i int
+		for _, k := range keys {
+			if !e.isAttr(k) {
+				continue
+			}
+			w.WriteByte(' ')
+			w.WriteString(attrNames[i])
+			w.WriteString(`="`)
+			s, _ := e.formatValue(vv[k])
+			e.writeEscaped(w, s, true)
+			w.WriteByte('"')
+			i++
+		}
+	}
+	if scope.decl == nil {
+		scope = ns
+	}
+
+	switch value.(type) {
+	case map[string]interface{}, *OrderedMap:
+		lenvv := len(vv)
 		// only attributes?
 		if cntAttr == lenvv {
 			break
 		}
-		// simple element? Note: '#text" is an invalid XML tag.
-		if v, ok := vv["#text"]; ok {
+		// mixed content?
+		if v, ok := vv[e.contentKey]; ok && e.contentKey != "" {
+			if cntAttr+1 < lenvv {
+				return encodeError(ErrTextKey, &mapPath{path, e.contentKey, -1}, e.contentKey+" key occurs with other non-attribute keys")
+			}
+			w.WriteByte('>')
+			if err := e.writeContent(w, v, scope, &mapPath{path, e.contentKey, -1}); err != nil {
+				return err
+			}
+			isSimple = true
+			endTag = true
+			break
+		}
+		// simple element? Note: '#text" and "#cdata" are invalid XML tags.
+		if k, ok := e.textKeyOf(vv); ok {
 			if cntAttr+1 < lenvv {
-				return errors.New("#text key occurs with other non-attribute keys")
+				return encodeError(ErrTextKey, &mapPath{path, k, -1}, k+" key occurs with other non-attribute keys")
 			}
-			*s += ">" + fmt.Sprintf("%v", v)
+			s, err := e.textValue(vv[k], &mapPath{path, k, -1})
+			if err != nil {
+				return err
+			}
+			w.WriteByte('>')
+			e.writeText(w, s, k == e.cdataKey)
+			isSimple = true
 			endTag = true
 			break
 		}
 		// close tag with possible attributes
-		*s += ">"
+		w.WriteByte('>')
+		w.WriteString(e.newline())
 		// something more complex
-		for k, v := range vv {
-			if k[:1] == "-" {
+		for _, k := range keys {
+			var err error
+			switch {
+			case e.isAttr(k):
 				continue
+			case isMarkup(k):
+				err = e.writeMarkup(w, k, vv[k], pad+e.indent, false, &mapPath{path, k, -1})
+			default:
+				err = e.mapToXml(w, k, vv[k], pad+e.indent, scope, &mapPath{path, k, -1})
+			}
+			if err != nil {
+				return err
 			}
-			mapToXml(s, k, v)
 		}
 		endTag = true
-	case []interface{}:
-		for _, v := range value.([]interface{}) {
-			mapToXml(s, key, v)
+	case itemList:
+		list := value.(itemList)
+		if len(list.items) == 0 {
+			break
 		}
-		return nil
+		w.WriteByte('>')
+		w.WriteString(e.newline())
+		for i, v := range list.items {
+			if inner, ok := v.([]interface{}); ok {
+				v = e.newItemList(list.itemTag, inner)
+			}
+			if err := e.mapToXml(w, list.itemTag, v, pad+e.indent, scope, &mapPath{path, "", i}); err != nil {
+				return err
+			}
+		}
+		endTag = true
 	case nil:
 		// terminate the tag
 		break
 	default: // handle anything - even goofy stuff
-		var tmp string
-		switch value.(type) {
-		case string, float64, bool, int, int32, int64, float32:
-			tmp = fmt.Sprintf("%v", value)
-		case []byte:			// NOTE: byte is just an alias for uint8
-			// similar to how xml.Marshal handles []byte structure members
-			tmp = fmt.Sprintf("%v", string(value.([]byte)))
-		default:
-			v, err := xml.Marshal(value)
-			if err != nil {
-				tmp = "UNKNOWN"
-			} else {
-				tmp = string(v)
-			}
+		w.WriteByte('>')
+		if isMarshaled {
+			w.WriteString(e.newline())
+			w.Write(marshaled)
+			w.WriteString(e.newline())
+		} else {
+			s, _ := e.formatValue(value)
+			e.writeText(w, s, false)
+			isSimple = true
 		}
-		*s += ">" + tmp
 		endTag = true
 	}
 
 	if endTag {
-		*s += "</" + key + ">"
-	} else if useGoXmlEmptyElemSyntax {
-		*s += "></" + key + ">"
+		if !isSimple {
+			w.WriteString(pad)
+		}
+		w.WriteString("</")
+		w.WriteString(tag)
+		w.WriteByte('>')
+	} else if e.goEmptyElem {
+		w.WriteString("></")
+		w.WriteString(tag)
+		w.WriteByte('>')
 	} else {
-		*s += "/>"
+		w.WriteString("/>")
 	}
+	w.WriteString(e.newline())
 	return nil
 }
+
+// escapeString returns s with the XML special characters replaced by entity
+// references.  See escapeTo().
+func escapeString(s string, attr, minimal bool) string {
+	var b strings.Builder
+	escapeTo(&b, s, attr, minimal)
+	return b.String()
+}
+
+// escapeTo writes s to w with the XML special characters replaced by entity
+// references.  Characters and byte sequences that are not legal XML 1.0 characters
+// are replaced by U+FFFD.  If attr is true, tab and newline are also written as
+// character references so they survive attribute value normalization.  If minimal
+// is true, > and ' - and " in character data - are written as is.
+func escapeTo(w io.StringWriter, s string, attr, minimal bool) {
+	last := 0
+	for i := 0; i < len(s); {
+		r, width := rune(s[i]), 1
+		if r >= utf8.RuneSelf {
+			r, width = utf8.DecodeRuneInString(s[i:])
+		}
+		var esc string
+		switch {
+		case r == '&':
+			esc = "&amp;"
+		case r == '<':
+			esc = "&lt;"
+		case r == '>' && !minimal:
+			esc = "&gt;"
+		case r == '"' && (attr || !minimal):
+			esc = "&quot;"
+		case r == '\'' && !minimal:
+			esc = "&apos;"
+		case r == '\r':
+			esc = "&#xD;"
+		case r == '\t' && attr:
+			esc = "&#x9;"
+		case r == '\n' && attr:
+			esc = "&#xA;"
+		case r == utf8.RuneError && width == 1, !isXmlChar(r):
+			esc = "\uFFFD"
+		default:
+			i += width
+			continue
+		}
+		w.WriteString(s[last:i])
+		w.WriteString(esc)
+		i += width
+		last = i
+	}
+	w.WriteString(s[last:])
+}
+
+// isXmlChar reports whether r is in the XML 1.0 Char production.
+func isXmlChar(r rune) bool {
+	return r == 0x09 ||
+		r == 0x0A ||
+		r == 0x0D ||
+		r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= 0x10FFFF
+}
@@ -0,0 +1,101 @@
+// j2x_cdata.go - character data encoded as CDATA sections
+
+package j2x
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// CDataPolicy selects which text values are encoded as CDATA sections.
+type CDataPolicy int
+
+const (
+	// CDataNever escapes text values; only the values of the "#cdata" key are encoded
+	// as CDATA sections.  The default.
+	CDataNever CDataPolicy = iota
+	// CDataAlways encodes every non-empty text value of an element as a CDATA section.
+	CDataAlways
+	// CDataWhenNeeded encodes the text values that contain markup characters - &, < or > -
+	// as CDATA sections and escapes the others.
+	CDataWhenNeeded
+)
+
+// CData sets the policy for encoding the text of elements as CDATA sections; attribute
+// values are always escaped.
+func CData(p CDataPolicy) Option {
+	return func(e *Encoder) {
+		e.cdataPolicy = p
+	}
+}
+
+// CDataKey sets the map key whose value is encoded as a CDATA section, like the text key
+// is encoded as character data; the default is "#cdata".
+func CDataKey(key string) Option {
+	return func(e *Encoder) {
+		e.cdataKey = key
+	}
+}
+
+// textKeyOf returns the text key or the CDATA key of the map m, if it has one.  An empty
+// key is not a text or CDATA key, as with isMember().
+func (e *Encoder) textKeyOf(m map[string]interface{}) (string, bool) {
+	if _, ok := m[e.textKey]; ok && e.textKey != "" {
+		return e.textKey, true
+	}
+	if _, ok := m[e.cdataKey]; ok && e.cdataKey != "" {
+		return e.cdataKey, true
+	}
+	return "", false
+}
+
+// writeText writes the text of an element to w, as a CDATA section if cdata is true or the
+// CDATA policy selects it, otherwise applying the escaping policy.
+func (e *Encoder) writeText(w xmlWriter, s string, cdata bool) {
+	if s != "" && (cdata || e.cdataPolicy == CDataAlways ||
+		e.cdataPolicy == CDataWhenNeeded && strings.ContainsAny(s, "&<>")) {
+		writeCData(w, s, e.charset)
+		return
+	}
+	e.writeEscaped(w, s, false)
+}
+
+// writeCData writes s to w as a CDATA section.  An embedded "]]>" is split across two
+// sections, a carriage return - which a CDATA section can't keep - is written as a
+// character reference between sections, and characters that are not legal in XML are
+// replaced by U+FFFD.  If enc, the output character encoding, is not nil, the characters
+// that it can't represent are also written as character references between sections.
+func writeCData(w xmlWriter, s string, enc func(rune) (byte, bool)) {
+	w.WriteString("<![CDATA[")
+	last := 0
+	for i := 0; i < len(s); {
+		r, width := rune(s[i]), 1
+		if r >= utf8.RuneSelf {
+			r, width = utf8.DecodeRuneInString(s[i:])
+		}
+		var esc string
+		switch {
+		case r == '>' && strings.HasSuffix(s[:i], "]]"):
+			esc = "]]><![CDATA[>"
+		case r == '\r':
+			esc = "]]>&#xD;<![CDATA["
+		case r == utf8.RuneError && width == 1, !isXmlChar(r):
+			r, esc = utf8.RuneError, "\uFFFD"
+		}
+		if enc != nil && r >= utf8.RuneSelf {
+			if _, ok := enc(r); !ok {
+				esc = "]]>" + charRef(r) + "<![CDATA["
+			}
+		}
+		if esc == "" {
+			i += width
+			continue
+		}
+		w.WriteString(s[last:i])
+		w.WriteString(esc)
+		i += width
+		last = i
+	}
+	w.WriteString(s[last:])
+	w.WriteString("]]>")
+}
@@ -0,0 +1,205 @@
+// j2x_charset.go - XML declaration and output character encodings
+
+package j2x
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Declaration writes an XML declaration, <?xml version="1.0" encoding="UTF-8"?>, before
+// the root element.  The version must be "1.0" or "1.1"; an empty version is "1.0" and an
+// empty encoding is "UTF-8".  An empty standalone is omitted, otherwise it must be "yes"
+// or "no".
+//
+// Besides UTF-8, the ISO-8859-1 (Latin-1), Windows-1252 and US-ASCII encodings are
+// supported.  For them the document is transcoded and characters that the encoding
+// can't represent are written as character references, "&#x20AC;".  Since character
+// references are not allowed in names, a name with such a character is an ErrInvalidName
+// error.
+func Declaration(version, encoding, standalone string) Option {
+	return func(e *Encoder) {
+		switch version {
+		case "":
+			version = "1.0"
+		case "1.0", "1.1":
+		default:
+			e.optErr = errors.New("XML version is not 1.0 or 1.1: " + version)
+			return
+		}
+		if encoding == "" {
+			encoding = "UTF-8"
+		}
+		switch standalone {
+		case "", "yes", "no":
+		default:
+			e.optErr = errors.New("standalone value is not yes or no: " + standalone)
+			return
+		}
+		enc, err := charset(encoding)
+		if err != nil {
+			e.optErr = err
+			return
+		}
+		e.decl = `<?xml version="` + version + `" encoding="` + encoding + `"`
+		if standalone != "" {
+			e.decl += ` standalone="` + standalone + `"`
+		}
+		e.decl += "?>\n"
+		e.charset = enc
+	}
+}
+
+// charset returns the function that encodes a rune in the named character encoding, or
+// nil for UTF-8.
+func charset(name string) (func(rune) (byte, bool), error) {
+	switch strings.ToUpper(strings.Replace(name, "_", "-", -1)) {
+	case "UTF-8", "UTF8":
+		return nil, nil
+	case "ISO-8859-1", "ISO8859-1", "LATIN1", "LATIN-1", "L1":
+		return latin1, nil
+	case "WINDOWS-1252", "CP1252":
+		return windows1252, nil
+	case "US-ASCII", "ASCII":
+		return ascii, nil
+	}
+	return nil, errors.New("unsupported encoding: " + name)
+}
+
+func ascii(r rune) (byte, bool) {
+	return byte(r), r < 0x80
+}
+
+func latin1(r rune) (byte, bool) {
+	return byte(r), r < 0x100
+}
+
+// windows1252High maps the Windows-1252 bytes 0x80-0x9F to runes; 0 is undefined.
+var windows1252High = [32]rune{
+	0x20AC, 0, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0, 0x017D, 0,
+	0, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0, 0x017E, 0x0178,
+}
+
+func windows1252(r rune) (byte, bool) {
+	if r < 0x80 || r >= 0xA0 && r < 0x100 {
+		return byte(r), true
+	}
+	for i, hr := range windows1252High {
+		if hr == r && hr != 0 {
+			return byte(0x80 + i), true
+		}
+	}
+	return 0, false
+}
+
+// charsetWriter transcodes UTF-8 to a single-byte character encoding.
+type charsetWriter struct {
+	w       io.Writer
+	enc     func(rune) (byte, bool)
+	partial []byte // incomplete UTF-8 sequence at the end of the last Write
+	buf     []byte
+}
+
+func (cw *charsetWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(cw.partial) > 0 {
+		p = append(cw.partial, p...)
+		cw.partial = nil
+	}
+	cw.buf = cw.buf[:0]
+	for len(p) > 0 {
+		if !utf8.FullRune(p) {
+			cw.partial = append([]byte(nil), p...)
+			break
+		}
+		r, size := utf8.DecodeRune(p)
+		p = p[size:]
+		if b, ok := cw.enc(r); ok {
+			cw.buf = append(cw.buf, b)
+		} else {
+			cw.buf = append(cw.buf, charRef(r)...)
+		}
+	}
+	if _, err := cw.w.Write(cw.buf); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// charRef returns the hexadecimal character reference for r, "&#x20AC;".
+func charRef(r rune) string {
+	return "&#x" + strings.ToUpper(strconv.FormatInt(int64(r), 16)) + ";"
+}
+
+// charsetReader returns a reader that transcodes the input from the named character
+// encoding to UTF-8, for xml.Decoder.CharsetReader.  The reader reads one byte at a
+// time, so it doesn't read beyond the document.
+func charsetReader(name string, input io.Reader) (io.Reader, error) {
+	enc, err := charset(name)
+	if err != nil {
+		return nil, err
+	}
+	if enc == nil {
+		return input, nil
+	}
+	// the inverse of enc
+	var dec [256]rune
+	for r := rune(0); r < 0x2200; r++ {
+		if b, ok := enc(r); ok {
+			dec[b] = r
+		}
+	}
+	br, ok := input.(io.ByteReader)
+	if !ok {
+		return nil, errors.New("charset input is not an io.ByteReader")
+	}
+	return &utf8Reader{r: br, dec: &dec}, nil
+}
+
+// utf8Reader transcodes a single-byte character encoding to UTF-8.
+type utf8Reader struct {
+	r       io.ByteReader
+	dec     *[256]rune
+	pending []byte
+}
+
+func (ur *utf8Reader) ReadByte() (byte, error) {
+	if len(ur.pending) == 0 {
+		b, err := ur.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		r := ur.dec[b]
+		if r == 0 && b != 0 {
+			r = utf8.RuneError
+		}
+		ur.pending = utf8.AppendRune(ur.pending[:0], r)
+	}
+	c := ur.pending[0]
+	ur.pending = ur.pending[1:]
+	return c, nil
+}
+
+func (ur *utf8Reader) Read(p []byte) (int, error) {
+	var n int
+	for n < len(p) {
+		c, err := ur.ReadByte()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		p[n] = c
+		n++
+		if len(ur.pending) == 0 {
+			break
+		}
+	}
+	return n, nil
+}
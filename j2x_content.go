@@ -0,0 +1,78 @@
+// j2x_content.go - mixed content: text interleaved with child elements
+
+package j2x
+
+import "fmt"
+
+// DefaultContentKey is the default map key of the mixed content of an element.
+const DefaultContentKey = "#content"
+
+// ContentKey sets the map key whose value is encoded as the mixed content of an element;
+// the default is "#content".  See MapToXml().
+func ContentKey(key string) Option {
+	return func(e *Encoder) {
+		e.contentKey = key
+	}
+}
+
+// inline returns the Encoder for mixed content, which is encoded without indentation
+// as the white space would be part of the text.
+func (e *Encoder) inline() *Encoder {
+	if !e.pretty {
+		return e
+	}
+	ie := *e
+	ie.pretty = false
+	ie.prefix = ""
+	ie.indent = ""
+	return &ie
+}
+
+// writeContent writes the value of the content key, v, as mixed content.  A list holds
+// text values and maps: the keys of a map are encoded as child elements - or as text,
+// CDATA sections and markup for the text, CDATA and markup keys - in key order.
+// Any other value is encoded as a list with the value.
+func (e *Encoder) writeContent(w xmlWriter, v interface{}, ns *nsScope, path *mapPath) error {
+	list, ok := v.([]interface{})
+	if !ok {
+		list = []interface{}{v}
+	}
+	ie := e.inline()
+	for i, item := range list {
+		ipath := &mapPath{path, "", i}
+		switch item.(type) {
+		case nil:
+		case []interface{}:
+			return encodeError(ErrTextKey, ipath, e.contentKey+" item is a list")
+		case map[string]interface{}, *OrderedMap:
+			vv, keys := keysOf(item)
+			for _, k := range keys {
+				kpath := &mapPath{ipath, k, -1}
+				var err error
+				switch {
+				case isMarkup(k):
+					err = ie.writeMarkup(w, k, vv[k], "", false, kpath)
+				case k != "" && (k == e.textKey || k == e.cdataKey):
+					var s string
+					if s, err = ie.textValue(vv[k], kpath); err == nil {
+						ie.writeText(w, s, k == e.cdataKey)
+					}
+				case e.isAttr(k):
+					err = encodeError(ErrTextKey, kpath, fmt.Sprintf("attribute key in %s item", e.contentKey))
+				default:
+					err = ie.mapToXml(w, k, vv[k], "", ns, kpath)
+				}
+				if err != nil {
+					return err
+				}
+			}
+		default:
+			s, err := ie.textValue(item, ipath)
+			if err != nil {
+				return err
+			}
+			ie.writeText(w, s, false)
+		}
+	}
+	return nil
+}
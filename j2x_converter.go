@@ -0,0 +1,129 @@
+// j2x_converter.go - bulk conversion of a stream of JSON values
+
+package j2x
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// Converter converts the JSON values of a stream to XML documents one at a time.
+// Values may be separated by white space - as with NDJSON - or commas or be concatenated.
+//
+//	c := j2x.NewConverter(r, j2x.Indent("", "  "))
+//	for c.Next() {
+//		w.Write(c.XML())
+//	}
+//	if err := c.Err(); err != nil {
+//		// handle error
+//	}
+type Converter struct {
+	f      *framer
+	e      *Encoder
+	xml    bytes.Buffer
+	json   []byte
+	offset int64
+	index  int
+	err    error
+	done   bool
+}
+
+// NewConverter returns a Converter that reads JSON values from r and encodes them with
+// the options; with no options the documents are encoded as with NewEncoder().
+func NewConverter(r io.Reader, opts ...Option) *Converter {
+	c := &Converter{f: newFramer(r), index: -1}
+	c.e = NewEncoder(&c.xml, opts...)
+	return c
+}
+
+// RecordError is the error of a Converter or ConvertParallel() for the JSON value with the 0-based Index that
+// starts at byte Offset of the stream.
+type RecordError struct {
+	Index  int
+	Offset int64
+	Err    error
+}
+
+func (e *RecordError) Error() string {
+	return "record " + strconv.Itoa(e.Index) + " at offset " + strconv.FormatInt(e.Offset, 10) + ": " + e.Err.Error()
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// Next converts the next JSON value.  It returns false at the end of the stream or if
+// there is an error, which is then returned by Err().  If the value was read but can't
+// be encoded, the following values can still be converted by calling Next again.
+func (c *Converter) Next() bool {
+	if c.done {
+		return false
+	}
+	c.xml.Reset()
+	c.json = nil
+	c.err = nil
+	if err := c.f.skip(); err != nil {
+		return c.fail(err)
+	}
+	c.offset = c.f.offset
+	c.index++
+	jb, err := c.f.next()
+	if err != nil {
+		return c.fail(err)
+	}
+	c.json = jb
+	if err := c.e.EncodeJson(jb); err != nil {
+		c.xml.Reset()
+		c.err = &RecordError{c.index, c.offset, err}
+		return false
+	}
+	return true
+}
+
+// fail ends the conversion with an error reading the stream.
+func (c *Converter) fail(err error) bool {
+	c.done = true
+	if err == io.EOF {
+		return false
+	}
+	if err == io.ErrUnexpectedEOF || isSyntaxError(err) {
+		c.err = &RecordError{c.index, c.offset, err}
+	} else {
+		c.err = err
+	}
+	return false
+}
+
+func isSyntaxError(err error) bool {
+	_, ok := err.(*json.SyntaxError)
+	return ok
+}
+
+// XML returns the XML document of the current value.  It is overwritten by the next
+// call to Next.
+func (c *Converter) XML() []byte {
+	return c.xml.Bytes()
+}
+
+// JSON returns the source of the current value.
+func (c *Converter) JSON() []byte {
+	return c.json
+}
+
+// Offset returns the byte offset of the current value in the stream.
+func (c *Converter) Offset() int64 {
+	return c.offset
+}
+
+// Index returns the 0-based index of the current value in the stream.
+func (c *Converter) Index() int {
+	return c.index
+}
+
+// Err returns the error that ended the last call to Next, or nil at the end of the stream.
+// Errors of a value, including JSON syntax errors, are a *RecordError.
+func (c *Converter) Err() error {
+	return c.err
+}
@@ -0,0 +1,42 @@
+//go:build go1.23
+
+// j2x_converter_iter.go - range-over-func form of Converter
+
+package j2x
+
+import (
+	"iter"
+)
+
+// Record is a JSON value of a stream and its XML document.
+type Record struct {
+	XML    []byte
+	JSON   []byte
+	Offset int64
+	Index  int
+}
+
+// All returns an iterator over the records of the stream.  A value that can't be encoded
+// is yielded with a *RecordError and the iteration continues; an error reading the stream
+// is yielded last.  The XML of a Record is overwritten by the next iteration.
+//
+//	for rec, err := range j2x.NewConverter(r).All() {
+//		if err != nil {
+//			log.Println(err)
+//			continue
+//		}
+//		w.Write(rec.XML)
+//	}
+func (c *Converter) All() iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		for {
+			ok := c.Next()
+			if !ok && c.err == nil {
+				return
+			}
+			if !yield(Record{c.XML(), c.json, c.offset, c.index}, c.err) || c.done {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,309 @@
+// j2x_encoder.go - per-instance encoding options
+
+package j2x
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"time"
+)
+
+// EscapePolicy selects which characters are replaced by entity references in
+// character data and attribute values.
+type EscapePolicy int
+
+const (
+	DefaultAttrPrefix = "-"
+	DefaultTextKey    = "#text"
+	DefaultCDataKey   = "#cdata"
+	DefaultItemTag    = "item"
+)
+
+const (
+	// EscapeAll replaces &, <, >, ' and " by entity references; the default.
+	EscapeAll EscapePolicy = iota
+	// EscapeMinimal replaces only the characters that must be escaped: & and < in
+	// character data, plus " in attribute values.
+	EscapeMinimal
+	// EscapeNone writes values verbatim.  Only use it if the values are known to be
+	// well-formed XML character data.
+	EscapeNone
+)
+
+// Encoder writes XML documents encoded from map[string]interface{} values to an io.Writer.
+// Each Encoder carries its own settings, so Encoders with different conventions can be
+// used concurrently.  An Encoder itself is not safe for concurrent use.
+// See MapToXml() for encoding rules.
+type Encoder struct {
+	w            io.Writer
+	goEmptyElem  bool
+	rootTag      string
+	pretty       bool
+	prefix       string
+	indent       string
+	escapePolicy EscapePolicy
+	keyOrder     KeyOrder
+	attrPrefix   string
+	textKey      string
+	cdataKey     string
+	cdataPolicy  CDataPolicy
+	contentKey   string
+	namespaces   map[string]string // prefix to URI
+	nsPrefixes   map[string]string // URI to prefix
+	nsCheck      bool
+	itemTag      string
+	itemNamer    ItemNamer
+	namePolicy   NamePolicy
+	nameSub      string
+	nameTag      string
+	nameAttr     string
+	decl         string
+	charset      func(rune) (byte, bool)
+	maxBuffer    int
+	floatFmt     byte
+	floatPrec    int
+	timeLayout   string
+	valuePolicy  ValuePolicy
+	placeholder  string
+	fallback     FallbackFunc
+	optErr       error // invalid option value
+}
+
+// Option sets an Encoder setting.
+type Option func(*Encoder)
+
+// NewEncoder returns an Encoder that writes to w.  With no options the output is the same
+// as MapToXml() with the package defaults.
+func NewEncoder(w io.Writer, opts ...Option) *Encoder {
+	e := &Encoder{
+		w:           w,
+		attrPrefix:  DefaultAttrPrefix,
+		textKey:     DefaultTextKey,
+		cdataKey:    DefaultCDataKey,
+		contentKey:  DefaultContentKey,
+		itemTag:     DefaultItemTag,
+		nsCheck:     true,
+		nameSub:     "_",
+		nameTag:     "item",
+		nameAttr:    "key",
+		maxBuffer:   DefaultMaxBuffer,
+		floatFmt:    'g',
+		floatPrec:   -1,
+		timeLayout:  time.RFC3339Nano,
+		placeholder: "UNKNOWN",
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// GoXmlEmptyElemSyntax - if b is true, empty elements are encoded as <tag ...></tag> rather
+// than <tag .../>.
+func GoXmlEmptyElemSyntax(b bool) Option {
+	return func(e *Encoder) {
+		e.goEmptyElem = b
+	}
+}
+
+// RootTag sets the tag of the root element.  If it is not set, a map with a single key
+// uses the key as the root tag, otherwise DefaultRootTag is used.
+func RootTag(tag string) Option {
+	return func(e *Encoder) {
+		e.rootTag = tag
+	}
+}
+
+// Indent encodes pretty XML: each element begins on a new line that starts with prefix
+// followed by one copy of indent for each level of nesting.  See MapToXmlIndent().
+func Indent(prefix, indent string) Option {
+	return func(e *Encoder) {
+		e.pretty = true
+		e.prefix = prefix
+		e.indent = indent
+	}
+}
+
+// ItemTag sets the tag of the elements that hold the items of a list that is encoded as
+// the root element, such as a top-level JSON array, or of a list in a list when lists
+// are not wrapped; the default is "item".  See WrapArrays().
+func ItemTag(tag string) Option {
+	return func(e *Encoder) {
+		e.itemTag = tag
+	}
+}
+
+// AttrPrefix sets the prefix of the map keys that are encoded as attributes; the default is
+// "-".  Use "@" for BadgerFish-style JSON.  If prefix is "", no keys are encoded as attributes.
+func AttrPrefix(prefix string) Option {
+	return func(e *Encoder) {
+		e.attrPrefix = prefix
+	}
+}
+
+// TextKey sets the map key whose value is encoded as the character data of an element
+// with attributes; the default is "#text".  Use "$" for BadgerFish-style JSON or "$t" for
+// GData-style JSON.  With an empty key no map key is encoded as character data.
+func TextKey(key string) Option {
+	return func(e *Encoder) {
+		e.textKey = key
+	}
+}
+
+// Escaping sets the escaping policy for character data and attribute values.
+func Escaping(p EscapePolicy) Option {
+	return func(e *Encoder) {
+		e.escapePolicy = p
+	}
+}
+
+// Ordering sets the key order used by EncodeJson(); the default is SortedKeys.
+func Ordering(o KeyOrder) Option {
+	return func(e *Encoder) {
+		e.keyOrder = o
+	}
+}
+
+// Encode writes the XML encoding of v, usually a map[string]interface{} or *OrderedMap value.
+// A list is encoded as the items of the root element, each with the item tag, and any
+// other value as the content of the root element.
+// If there is an error the XML that was encoded before it occurred has been written.
+func (e *Encoder) Encode(v interface{}) (err error) {
+	if e.optErr != nil {
+		return e.optErr
+	}
+
+	w, flush := e.writer()
+	defer func() {
+		if ferr := flush(); err == nil {
+			err = ferr
+		}
+	}()
+	w.WriteString(e.decl)
+	return e.encodeRoot(w, v, e.prefix, nil, true)
+}
+
+// writer returns the xmlWriter for the Encoder's io.Writer, transcoding to the declared
+// character encoding, and the function that flushes it.
+func (e *Encoder) writer() (xmlWriter, func() error) {
+	w := e.w
+	if e.charset != nil {
+		w = &charsetWriter{w: w, enc: e.charset}
+	}
+	if b, ok := w.(*bytes.Buffer); ok {
+		return b, func() error { return nil }
+	}
+	bw := bufio.NewWriter(w)
+	return bw, bw.Flush
+}
+
+// xmlWriter is implemented by *bytes.Buffer and *bufio.Writer; write errors of a
+// *bufio.Writer are returned by Flush().
+type xmlWriter interface {
+	io.Writer
+	io.StringWriter
+	io.ByteWriter
+}
+
+// writeEscaped writes s to w applying the Encoder's escaping policy.
+func (e *Encoder) writeEscaped(w xmlWriter, s string, attr bool) {
+	if e.escapePolicy == EscapeNone {
+		w.WriteString(s)
+		return
+	}
+	escapeTo(w, s, attr, e.escapePolicy == EscapeMinimal)
+}
+
+// EncodeJson writes the XML encoding of a JSON value.  See Encode().
+func (e *Encoder) EncodeJson(jsonString []byte) error {
+	v, err := e.decodeJson(jsonString)
+	if err != nil {
+		return err
+	}
+	return e.Encode(v)
+}
+
+// decodeJson decodes a JSON value, preserving the key order for the JsonKeyOrder ordering.
+// Numbers are decoded as json.Number values.
+func (e *Encoder) decodeJson(jsonString []byte) (interface{}, error) {
+	if e.keyOrder == JsonKeyOrder {
+		return jsonToOrdered(jsonString)
+	}
+	var v interface{}
+	err := unmarshalJson(jsonString, &v)
+	return v, err
+}
+
+// rootElem returns the tag and value of the root element for the value m.  If m is a map
+// with len(m) == 1 and no root tag is set, then the map key is used as the root tag unless
+// its value is a list that is not wrapped or the key is a member of an element - see
+// isMember().  A list is encoded as the root element with
+// an item element for each value.  The path of the value is returned for errors.
+func (e *Encoder) rootElem(m interface{}) (string, interface{}, *mapPath) {
+	tag := e.rootTag
+	if tag == "" {
+		tag = DefaultRootTag
+	}
+	switch m.(type) {
+	case map[string]interface{}, *OrderedMap:
+	case []interface{}:
+		return tag, itemList{m.([]interface{}), e.itemTag}, nil
+	default:
+		return tag, m, nil
+	}
+	if e.rootTag != "" {
+		return e.rootTag, m, nil
+	}
+	mm, keys := keysOf(m)
+	if len(keys) == 1 && !e.isMember(keys[0]) {
+		if _, ok := mm[keys[0]].([]interface{}); !ok || e.itemNamer != nil {
+			return keys[0], mm[keys[0]], &mapPath{key: keys[0], index: -1}
+		}
+	}
+	return DefaultRootTag, m, nil
+}
+
+// isAttr reports whether the map key k is encoded as an attribute.  The text, CDATA and
+// content keys and markup keys are never attributes, even if they start with the attribute
+// prefix.
+func (e *Encoder) isAttr(k string) bool {
+	return e.attrPrefix != "" && len(k) > len(e.attrPrefix) && strings.HasPrefix(k, e.attrPrefix) &&
+		k != e.textKey && k != e.cdataKey && k != e.contentKey && !isMarkup(k)
+}
+
+// isMember reports whether the map key k is an attribute or the text, CDATA or content key,
+// which can't be the tag of an element.
+func (e *Encoder) isMember(k string) bool {
+	return e.isAttr(k) || k != "" && (k == e.textKey || k == e.cdataKey || k == e.contentKey)
+}
+
+// escape applies the Encoder's escaping policy to s.
+func (e *Encoder) escape(s string, attr bool) string {
+	if e.escapePolicy == EscapeNone {
+		return s
+	}
+	return escapeString(s, attr, e.escapePolicy == EscapeMinimal)
+}
+
+// newline returns the line break written after each element of pretty XML.
+func (e *Encoder) newline() string {
+	if e.pretty {
+		return "\n"
+	}
+	return ""
+}
+
+// defaultEncoder returns the Encoder used by the package-level functions; it has the
+// setting of UseGoXmlEmptyElemSyntax() and, if provided, the rootTag.
+func defaultEncoder(w io.Writer, rootTag []string, opts ...Option) *Encoder {
+	e := NewEncoder(w, GoXmlEmptyElemSyntax(useGoXmlEmptyElemSyntax))
+	if len(rootTag) == 1 {
+		e.rootTag = rootTag[0]
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
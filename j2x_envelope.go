@@ -0,0 +1,150 @@
+// j2x_envelope.go - a stream of records encoded as the children of one root element
+
+package j2x
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+)
+
+// EnvelopeWriter writes an XML document whose root element, the envelope, holds a child
+// element for each record that is encoded.  The envelope start tag, preceded by the XML
+// declaration if the Declaration() option is set, is written with the first record and
+// the end tag by Close().
+//
+//	ew := j2x.NewEnvelopeWriter(w, "records", map[string]string{"version": "1"})
+//	for _, m := range records {
+//		if err := ew.Encode(m); err != nil {
+//			// handle error
+//		}
+//	}
+//	err := ew.Close()
+type EnvelopeWriter struct {
+	e       *Encoder
+	w       xmlWriter
+	flush   func() error
+	tag     string
+	attrs   map[string]string
+	scope   *nsScope
+	buf     bytes.Buffer
+	started bool
+	closed  bool
+}
+
+// NewEnvelopeWriter returns an EnvelopeWriter that writes to w an envelope element tagged
+// tag with the attributes attrs - names without the attribute prefix, encoded in sorted
+// order.  The options are applied to the records as for NewEncoder().  Namespaces declared
+// by "xmlns" attributes of the envelope are in scope for the records.
+func NewEnvelopeWriter(w io.Writer, tag string, attrs map[string]string, opts ...Option) *EnvelopeWriter {
+	ew := &EnvelopeWriter{e: NewEncoder(w, opts...), tag: tag, attrs: attrs, scope: &nsScope{}}
+	ew.w, ew.flush = ew.e.writer()
+	for name, uri := range attrs {
+		if isNsDecl(name) {
+			ew.scope.bind(nsDeclPrefix(name), uri)
+		}
+	}
+	return ew
+}
+
+// start writes the XML declaration and the start tag of the envelope.
+func (ew *EnvelopeWriter) start() error {
+	if ew.closed {
+		return errors.New("envelope writer is closed")
+	}
+	if ew.started {
+		return nil
+	}
+	e := ew.e
+	if e.optErr != nil {
+		return e.optErr
+	}
+	tag, _, err := e.validName(ew.tag, false, nil)
+	if err != nil || tag != ew.tag {
+		return errors.New("invalid envelope tag: " + ew.tag)
+	}
+	names := make([]string, 0, len(ew.attrs))
+	for name := range ew.attrs {
+		if valid, _, err := e.validName(name, true, nil); err != nil || valid != name {
+			return errors.New("invalid envelope attribute name: " + name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ew.w.WriteString(e.decl + e.prefix + "<" + tag)
+	for _, name := range names {
+		ew.w.WriteString(" " + name + `="`)
+		e.writeEscaped(ew.w, ew.attrs[name], true)
+		ew.w.WriteByte('"')
+	}
+	ew.w.WriteString(">" + e.newline())
+	ew.started = true
+	return nil
+}
+
+// Encode writes the XML encoding of v, as Encoder.Encode() encodes it, as a child of the
+// envelope.  If v can't be encoded nothing is written, so the document is still well-formed.
+func (ew *EnvelopeWriter) Encode(v interface{}) error {
+	if err := ew.start(); err != nil {
+		return err
+	}
+	e := ew.e
+	ew.buf.Reset()
+	if err := e.encodeRoot(&ew.buf, v, e.prefix+e.indent, ew.scope, false); err != nil {
+		return err
+	}
+	_, err := ew.w.Write(ew.buf.Bytes())
+	return err
+}
+
+// EncodeJson writes the XML encoding of a JSON value as a child of the envelope.
+// See Encode().
+func (ew *EnvelopeWriter) EncodeJson(jsonString []byte) error {
+	v, err := ew.e.decodeJson(jsonString)
+	if err != nil {
+		return err
+	}
+	return ew.Encode(v)
+}
+
+// Close writes the end tag of the envelope, and the start tag if there are no records,
+// and flushes the output.  It doesn't close the underlying io.Writer.
+func (ew *EnvelopeWriter) Close() error {
+	if ew.closed {
+		return nil
+	}
+	if err := ew.start(); err != nil {
+		return err
+	}
+	ew.w.WriteString(ew.e.prefix + "</" + ew.tag + ">" + ew.e.newline())
+	ew.closed = true
+	return ew.flush()
+}
+
+// JsonReaderToEnvelope converts the JSON values read from rdr, as NewConverter() does,
+// and writes them as the records of an envelope.  See NewEnvelopeWriter().  If a value
+// can't be converted the envelope is closed and the *RecordError is returned.
+func JsonReaderToEnvelope(rdr io.Reader, wtr io.Writer, tag string, attrs map[string]string, opts ...Option) error {
+	ew := NewEnvelopeWriter(wtr, tag, attrs, opts...)
+	f := newFramer(rdr)
+	for index := 0; ; index++ {
+		err := f.skip()
+		offset := f.offset
+		var jb []byte
+		if err == nil {
+			jb, err = f.next()
+		}
+		if err == nil {
+			err = ew.EncodeJson(jb)
+		}
+		if err == io.EOF {
+			return ew.Close()
+		}
+		if err != nil {
+			ew.Close()
+			return &RecordError{index, offset, err}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// j2x_error.go - typed encoding errors
+
+package j2x
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ErrorKind classifies an EncodeError.
+type ErrorKind int
+
+const (
+	// ErrAttrValue - an attribute value is not a []byte, string, number or boolean value.
+	ErrAttrValue ErrorKind = iota + 1
+	// ErrInvalidName - a key can't be encoded as an element or attribute name.
+	ErrInvalidName
+	// ErrNamespace - a namespace prefix is not declared in scope.
+	ErrNamespace
+	// ErrTextKey - the text, CDATA or content key occurs with other non-attribute keys, or
+	// has a value that can't be encoded as text or mixed content.
+	ErrTextKey
+	// ErrMarshal - a value can't be encoded.
+	ErrMarshal
+	// ErrBufferLimit - Transcode() can't encode an attribute or a top-level key because the
+	// XML that it would have to precede has already been written.
+	ErrBufferLimit
+	// ErrMarkup - a comment, processing instruction or DOCTYPE declaration key has a value
+	// that can't be encoded, or is not allowed where it occurs.
+	ErrMarkup
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrAttrValue:
+		return "invalid attribute value"
+	case ErrInvalidName:
+		return "invalid name"
+	case ErrNamespace:
+		return "undeclared namespace prefix"
+	case ErrTextKey:
+		return "text key with other non-attribute keys"
+	case ErrMarshal:
+		return "unencodable value"
+	case ErrBufferLimit:
+		return "buffer limit exceeded"
+	case ErrMarkup:
+		return "invalid markup"
+	}
+	return "unknown error"
+}
+
+// EncodeError is returned by MapToXml(), Encoder.Encode(), etc. when a value can't be encoded.
+// Path locates the value in the map: the keys from the root map separated by "/", with
+// the 0-based index of list values in brackets - "/order/items[3]/-qty".
+type EncodeError struct {
+	Kind ErrorKind
+	Path string
+	Msg  string
+}
+
+func (e *EncodeError) Error() string {
+	return e.Kind.String() + " at " + e.Path + ": " + e.Msg
+}
+
+func encodeError(kind ErrorKind, path *mapPath, msg string) error {
+	return &EncodeError{Kind: kind, Path: path.String(), Msg: msg}
+}
+
+// mapPath locates a value in the map that is encoded; it is only rendered as a string
+// for errors.  A nil *mapPath is the root map.
+type mapPath struct {
+	parent *mapPath
+	key    string
+	index  int // list index, or -1 for a map key
+}
+
+func (p *mapPath) String() string {
+	if p == nil {
+		return "/"
+	}
+	var elems []*mapPath
+	for ; p != nil; p = p.parent {
+		elems = append(elems, p)
+	}
+	var b strings.Builder
+	for i := len(elems) - 1; i >= 0; i-- {
+		if elems[i].index >= 0 {
+			b.WriteString("[" + strconv.Itoa(elems[i].index) + "]")
+		} else {
+			b.WriteString("/" + elems[i].key)
+		}
+	}
+	return b.String()
+}
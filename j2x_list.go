@@ -0,0 +1,76 @@
+// j2x_list.go - encoding lists as a container element with item elements
+
+package j2x
+
+import (
+	"strings"
+)
+
+// ItemNamer returns the tag of the item elements for a list that is the value of key.
+type ItemNamer func(key string) string
+
+// WrapArrays encodes a list as a container element, tagged with the list's key, that holds
+// an item element for each list value; namer provides the item tag.  Thus, with
+// FixedItemName("item"), `{ "items":[ 1, 2 ] }` encodes as
+// `<items><item>1</item><item>2</item></items>` rather than `<items>1</items><items>2</items>`.
+// A list that is the single value of the map is then also used as the root element.
+func WrapArrays(namer ItemNamer) Option {
+	return func(e *Encoder) {
+		e.itemNamer = namer
+	}
+}
+
+// FixedItemName returns an ItemNamer that uses tag for all item elements.
+func FixedItemName(tag string) ItemNamer {
+	return func(string) string {
+		return tag
+	}
+}
+
+// SingularItemName is an ItemNamer that uses the singular of an English plural key -
+// "entries" has "entry" items, "boxes" has "box" items and "items" has "item" items.
+// If the key isn't a plural, the items are tagged DefaultItemTag.  The plural is recognized
+// by its suffix only: keys that end in "ss", "us" or "is" - "status", "bus", "analysis" -
+// and a few nouns such as "news" and "series" are taken as singular, and irregular plurals
+// - "people", "buses" - are not handled.  Use an ItemNamer of your own for such keys.
+func SingularItemName(key string) string {
+	if notPlural[key] {
+		return DefaultItemTag
+	}
+	switch {
+	case strings.HasSuffix(key, "ies") && len(key) > 3:
+		return key[:len(key)-3] + "y"
+	case strings.HasSuffix(key, "sses"), strings.HasSuffix(key, "shes"),
+		strings.HasSuffix(key, "ches"), strings.HasSuffix(key, "xes"), strings.HasSuffix(key, "zes"):
+		return key[:len(key)-2]
+	case strings.HasSuffix(key, "s") && len(key) > 1 && !strings.HasSuffix(key, "ss") &&
+		!strings.HasSuffix(key, "us") && !strings.HasSuffix(key, "is"):
+		return key[:len(key)-1]
+	}
+	return DefaultItemTag
+}
+
+// notPlural are common nouns that SingularItemName() would take as plurals.
+var notPlural = map[string]bool{
+	"news": true, "series": true, "species": true, "alias": true, "atlas": true, "bias": true,
+	"canvas": true, "gas": true, "lens": true, "means": true, "chaos": true, "thermos": true,
+}
+
+// itemList is a list encoded as an element that holds an item element for each value.
+type itemList struct {
+	items   []interface{}
+	itemTag string
+}
+
+// newItemList returns the itemList for the list that is the value of key.
+func (e *Encoder) newItemList(key string, list []interface{}) itemList {
+	return itemList{list, e.itemTagOf(key)}
+}
+
+// itemTagOf returns the tag of the item elements for a list that is the value of key.
+func (e *Encoder) itemTagOf(key string) string {
+	if e.itemNamer != nil {
+		return e.itemNamer(key)
+	}
+	return e.itemTag
+}
@@ -0,0 +1,210 @@
+// j2x_markup.go - comments, processing instructions and DOCTYPE declarations
+
+package j2x
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// The keys of markup that is not an element.  A processing instruction key is "?" followed
+// by the target, "?xml-stylesheet".
+const (
+	commentKey = "#comment"
+	doctypeKey = "!DOCTYPE"
+	piPrefix   = "?"
+)
+
+// isMarkup reports whether the map key k is encoded as a comment, a processing instruction
+// or a DOCTYPE declaration.
+func isMarkup(k string) bool {
+	return k == commentKey || k == doctypeKey || len(k) > len(piPrefix) && strings.HasPrefix(k, piPrefix)
+}
+
+// writeMarkup writes the markup for the key k - a node for the value v or, if v is a list,
+// for each of its values.  A DOCTYPE declaration is only written if doctype is true, before
+// the root element, and can't be a list; see checkDoctype().  pad is the indentation of the markup when encoding pretty XML.
+func (e *Encoder) writeMarkup(w xmlWriter, k string, v interface{}, pad string, doctype bool, path *mapPath) error {
+	if list, ok := v.([]interface{}); ok {
+		if k == doctypeKey {
+			return encodeError(ErrMarkup, path, "a document has only one DOCTYPE declaration")
+		}
+		for i, v := range list {
+			if err := e.writeMarkup(w, k, v, pad, doctype, &mapPath{path, "", i}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	s, ok := e.formatValue(v)
+	if !ok && v != nil {
+		return encodeError(ErrMarkup, path, fmt.Sprintf("%s value is a %T", k, v))
+	}
+	if i := strings.IndexFunc(s, func(r rune) bool { return r == utf8.RuneError || !isXmlChar(r) }); i >= 0 {
+		return encodeError(ErrMarkup, path, fmt.Sprintf("%s value has the character %q", k, s[i:i+1]))
+	}
+	// character references are not recognized in markup, so the declared encoding must
+	// represent every character
+	if e.charset != nil {
+		if i := strings.IndexFunc(s, func(r rune) bool { _, ok := e.charset(r); return !ok }); i >= 0 {
+			r, _ := utf8.DecodeRuneInString(s[i:])
+			return encodeError(ErrMarkup, path, fmt.Sprintf("%s value has the character %q, which the encoding can't represent", k, r))
+		}
+	}
+	var markup string
+	switch {
+	case k == commentKey:
+		if strings.Contains(s, "--") || strings.HasSuffix(s, "-") {
+			return encodeError(ErrMarkup, path, `comment contains "--" or ends with "-"`)
+		}
+		markup = "<!--" + s + "-->"
+	case k == doctypeKey:
+		if !doctype {
+			return encodeError(ErrMarkup, path, k+" key is only allowed before the root element")
+		}
+		if !validDoctype(s) {
+			return encodeError(ErrMarkup, path, "invalid DOCTYPE declaration: "+s)
+		}
+		markup = "<!DOCTYPE " + s + ">"
+	default:
+		target := k[len(piPrefix):]
+		if !isName(target) || strings.Contains(target, ":") || strings.EqualFold(target, "xml") {
+			return encodeError(ErrMarkup, path, "invalid processing instruction target: "+target)
+		}
+		if strings.Contains(s, "?>") {
+			return encodeError(ErrMarkup, path, `processing instruction contains "?>"`)
+		}
+		markup = "<?" + target
+		if s != "" {
+			markup += " " + s
+		}
+		markup += "?>"
+	}
+	w.WriteString(pad)
+	w.WriteString(markup)
+	w.WriteString(e.newline())
+	return nil
+}
+
+// validDoctype reports whether s, the content of a DOCTYPE declaration after the keyword,
+// starts with the name of the root element and is a single well-formed declaration that
+// ends with the internal subset, if it has one.
+func validDoctype(s string) bool {
+	name := s
+	if i := strings.IndexAny(s, " \t\r\n["); i >= 0 {
+		name = s[:i]
+	}
+	if !isName(name) {
+		return false
+	}
+	var quote byte
+scan:
+	for i := len(name); i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			if !strings.HasSuffix(strings.TrimRight(s, " \t\r\n"), "]") {
+				return false
+			}
+			break scan
+		case c == ']':
+			return false
+		}
+	}
+	d := xml.NewDecoder(strings.NewReader("<!DOCTYPE " + s + ">"))
+	t, err := d.RawToken()
+	if dir, ok := t.(xml.Directive); err != nil || !ok || string(dir) != "DOCTYPE "+s {
+		return false
+	}
+	_, err = d.RawToken()
+	return err == io.EOF
+}
+
+// checkDoctype checks that the DOCTYPE declaration v, which writeMarkup() accepted, names
+// the root element for the key root.  The prefix of a Clark-notation key is chosen when
+// the element is written, so only the local name is compared for it.
+func (e *Encoder) checkDoctype(v interface{}, root string, path *mapPath) error {
+	s, _ := e.formatValue(v)
+	name := s
+	if i := strings.IndexAny(s, " \t\r\n["); i >= 0 {
+		name = s[:i]
+	}
+	tag, _, err := e.validName(root, false, path)
+	if err != nil {
+		return nil // the root element reports it
+	}
+	if i := strings.Index(tag, "}"); strings.HasPrefix(tag, "{") && i >= 0 {
+		tag = tag[i+1:]
+		if j := strings.Index(name, ":"); j >= 0 {
+			name = name[j+1:]
+		}
+	}
+	if name != tag {
+		return encodeError(ErrMarkup, path, "DOCTYPE declaration names "+name+", not the root element "+tag)
+	}
+	return nil
+}
+
+// encodeRoot writes the root element for the value v, as Encode() does.  Markup keys of
+// a top-level map are not members of the root element: those that precede the first key
+// of an element, in key order, are written before the root element and the others after it.
+func (e *Encoder) encodeRoot(w xmlWriter, v interface{}, pad string, ns *nsScope, doctype bool) error {
+	vv, keys := keysOf(v)
+	var before, after, elems []string
+	for _, k := range keys {
+		switch {
+		case !isMarkup(k):
+			elems = append(elems, k)
+		case len(elems) == 0:
+			before = append(before, k)
+		default:
+			after = append(after, k)
+		}
+	}
+	if len(elems) < len(keys) {
+		if _, ok := v.(*OrderedMap); ok {
+			om := &OrderedMap{keys: elems, m: make(map[string]interface{}, len(elems))}
+			for _, k := range elems {
+				om.m[k] = vv[k]
+			}
+			v = om
+		} else {
+			m := make(map[string]interface{}, len(elems))
+			for _, k := range elems {
+				m[k] = vv[k]
+			}
+			v = m
+		}
+	}
+
+	key, value, path := e.rootElem(v)
+	for _, k := range before {
+		kpath := &mapPath{key: k, index: -1}
+		if err := e.writeMarkup(w, k, vv[k], pad, doctype, kpath); err != nil {
+			return err
+		}
+		if k == doctypeKey {
+			if err := e.checkDoctype(vv[k], key, kpath); err != nil {
+				return err
+			}
+		}
+	}
+	if err := e.mapToXml(w, key, value, pad, ns, path); err != nil {
+		return err
+	}
+	for _, k := range after {
+		if err := e.writeMarkup(w, k, vv[k], pad, false, &mapPath{key: k, index: -1}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
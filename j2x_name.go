@@ -0,0 +1,186 @@
+// j2x_name.go - validation of element and attribute names
+
+package j2x
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// NamePolicy selects how keys that are not valid XML names are encoded.
+type NamePolicy int
+
+const (
+	// NameError returns an *EncodeError with Kind ErrInvalidName; the default.
+	NameError NamePolicy = iota
+	// NameReplace replaces the characters that are not allowed in a name with the substitute
+	// string - "2nd item" is encoded as "_2nd_item".  See NameSubstitute().
+	NameReplace
+	// NameAsAttr encodes the element with a generic tag and keeps the key in an attribute -
+	// "2nd item" is encoded as <item key="2nd item">.  See NameElement().  Attribute keys
+	// are encoded as for NameReplace.
+	NameAsAttr
+)
+
+// Names sets the policy for keys that are not valid XML names.
+func Names(p NamePolicy) Option {
+	return func(e *Encoder) {
+		e.namePolicy = p
+	}
+}
+
+// NameSubstitute sets the replacement for characters not allowed in names with the
+// NameReplace policy; the default is "_".
+func NameSubstitute(sub string) Option {
+	return func(e *Encoder) {
+		e.nameSub = sub
+	}
+}
+
+// NameElement sets the tag of the generic element and the name of the attribute that
+// holds the key with the NameAsAttr policy; the defaults are "item" and "key".
+func NameElement(tag, attr string) Option {
+	return func(e *Encoder) {
+		e.nameTag = tag
+		e.nameAttr = attr
+	}
+}
+
+// validName checks that name is an XML name - for a Clark-notation name, "{uri}local",
+// the local part is checked - and applies the name policy if it isn't.  For the NameAsAttr
+// policy keyAttr is the attribute that holds the key.  path locates the name for errors.
+// A name must also be representable in the output character encoding; see Declaration().
+func (e *Encoder) validName(name string, attr bool, path *mapPath) (valid, keyAttr string, err error) {
+	if valid, keyAttr, err = e.policyName(name, attr, path); err != nil || e.charset == nil {
+		return valid, keyAttr, err
+	}
+	local := valid
+	if i := strings.Index(valid, "}"); strings.HasPrefix(valid, "{") && i >= 0 {
+		local = valid[i+1:]
+	}
+	for _, r := range local {
+		if _, ok := e.charset(r); !ok {
+			return "", "", encodeError(ErrInvalidName, path, fmt.Sprintf("name %s has the character %q, which the encoding can't represent", name, r))
+		}
+	}
+	return valid, keyAttr, nil
+}
+
+// policyName implements validName() without the character encoding check.
+func (e *Encoder) policyName(name string, attr bool, path *mapPath) (valid, keyAttr string, err error) {
+	var clark string
+	local := name
+	if strings.HasPrefix(name, "{") {
+		if i := strings.Index(name, "}"); i >= 0 {
+			clark, local = name[:i+1], name[i+1:]
+		}
+	}
+	if isName(local) {
+		return name, "", nil
+	}
+
+	switch e.namePolicy {
+	case NameAsAttr:
+		if !attr {
+			return e.nameTag, ` ` + e.nameAttr + `="` + e.escape(name, true) + `"`, nil
+		}
+		fallthrough
+	case NameReplace:
+		if fixed := clark + replaceName(local, e.nameSub); isName(fixed[len(clark):]) {
+			return fixed, "", nil
+		}
+	}
+	return "", "", encodeError(ErrInvalidName, path, "invalid name: "+name)
+}
+
+// attrSet holds the attribute names of a start tag, to catch an attribute that is
+// written twice.
+type attrSet map[string]bool
+
+// newAttrSet returns the attrSet of a start tag; keyAttr is the attribute of the NameAsAttr
+// policy, if any, that validName() returned.
+func (e *Encoder) newAttrSet(keyAttr string) attrSet {
+	s := make(attrSet)
+	if keyAttr != "" {
+		s[e.nameAttr] = true
+	}
+	return s
+}
+
+// add adds the attribute name, which is qualified, to the set.  path locates the name for
+// errors.
+func (s attrSet) add(name string, path *mapPath) error {
+	if s[name] {
+		return encodeError(ErrInvalidName, path, "duplicate attribute name: "+name)
+	}
+	s[name] = true
+	return nil
+}
+
+// replaceName replaces the characters of s that are not allowed in an XML name with sub.
+// If the first character is only allowed after the start of a name, sub is prepended.
+func replaceName(s, sub string) string {
+	if s == "" {
+		return sub
+	}
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == utf8.RuneError:
+			b.WriteString(sub)
+		case i == 0 && !isNameStartChar(r):
+			b.WriteString(sub)
+			if isNameChar(r) {
+				b.WriteRune(r)
+			}
+		case !isNameChar(r):
+			b.WriteString(sub)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// isName reports whether s matches the XML 1.0 Name production.
+func isName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r == utf8.RuneError {
+			return false
+		}
+		if i == 0 && !isNameStartChar(r) || !isNameChar(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isNameStartChar(r rune) bool {
+	return r >= 'a' && r <= 'z' ||
+		r >= 'A' && r <= 'Z' ||
+		r == '_' || r == ':' ||
+		r >= 0xC0 && r <= 0xD6 ||
+		r >= 0xD8 && r <= 0xF6 ||
+		r >= 0xF8 && r <= 0x2FF ||
+		r >= 0x370 && r <= 0x37D ||
+		r >= 0x37F && r <= 0x1FFF ||
+		r >= 0x200C && r <= 0x200D ||
+		r >= 0x2070 && r <= 0x218F ||
+		r >= 0x2C00 && r <= 0x2FEF ||
+		r >= 0x3001 && r <= 0xD7FF ||
+		r >= 0xF900 && r <= 0xFDCF ||
+		r >= 0xFDF0 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= 0xEFFFF
+}
+
+func isNameChar(r rune) bool {
+	return isNameStartChar(r) ||
+		r >= '0' && r <= '9' ||
+		r == '-' || r == '.' || r == 0xB7 ||
+		r >= 0x300 && r <= 0x36F ||
+		r >= 0x203F && r <= 0x2040
+}
@@ -0,0 +1,161 @@
+// j2x_namespace.go - XML namespace handling for prefixed and Clark-notation keys
+
+package j2x
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// XmlNamespace is the namespace bound to the "xml" prefix.
+const XmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// Namespaces sets a prefix-to-URI table.  A prefix in the table that is used by an element
+// or attribute key but is not declared in scope is declared on that element.  The table
+// also provides the prefix for Clark-notation keys, "{uri}local", whose URI is not in scope.
+func Namespaces(ns map[string]string) Option {
+	return func(e *Encoder) {
+		e.namespaces = make(map[string]string, len(ns))
+		e.nsPrefixes = make(map[string]string, len(ns))
+		prefixes := make([]string, 0, len(ns))
+		for p := range ns {
+			prefixes = append(prefixes, p)
+		}
+		sort.Strings(prefixes)
+		for _, p := range prefixes {
+			e.namespaces[p] = ns[p]
+			if _, ok := e.nsPrefixes[ns[p]]; !ok {
+				e.nsPrefixes[ns[p]] = p
+			}
+		}
+	}
+}
+
+// NamespaceCheck - if b is true, the default, it is an error if an element or attribute key
+// has a prefix that is not declared in scope by a "-xmlns:prefix" attribute or the
+// Namespaces() table.
+func NamespaceCheck(b bool) Option {
+	return func(e *Encoder) {
+		e.nsCheck = b
+	}
+}
+
+// nsScope holds the namespace declarations of an element; parent holds those of the
+// enclosing elements.
+type nsScope struct {
+	parent *nsScope
+	decl   map[string]string // prefix - "" for the default namespace - to URI
+}
+
+func (ns *nsScope) bind(prefix, uri string) {
+	if ns.decl == nil {
+		ns.decl = make(map[string]string)
+	}
+	ns.decl[prefix] = uri
+}
+
+// uri returns the URI bound to prefix in scope.
+func (ns *nsScope) uri(prefix string) (string, bool) {
+	for n := ns; n != nil; n = n.parent {
+		if uri, ok := n.decl[prefix]; ok {
+			return uri, true
+		}
+	}
+	if prefix == "xml" {
+		return XmlNamespace, true
+	}
+	return "", false
+}
+
+// prefix returns a prefix in scope that is bound to uri.  The default namespace
+// is only considered if dflt is true.
+func (ns *nsScope) prefix(uri string, dflt bool) (string, bool) {
+	for n := ns; n != nil; n = n.parent {
+		var found []string
+		for p, u := range n.decl {
+			if u == uri && (p != "" || dflt) {
+				found = append(found, p)
+			}
+		}
+		sort.Strings(found)
+		for _, p := range found {
+			// the prefix may be rebound by an inner element
+			if u, _ := ns.uri(p); u == uri {
+				return p, true
+			}
+		}
+	}
+	if uri == XmlNamespace {
+		return "xml", true
+	}
+	return "", false
+}
+
+// isNsDecl reports whether the attribute name is a namespace declaration.
+func isNsDecl(name string) bool {
+	return name == "xmlns" || strings.HasPrefix(name, "xmlns:")
+}
+
+// nsDeclPrefix returns the prefix declared by a namespace declaration attribute name,
+// "" for the default namespace.
+func nsDeclPrefix(name string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(name, "xmlns"), ":")
+}
+
+// qualify returns the name to encode for an element or attribute name.  A Clark-notation
+// name, "{uri}local", is given a prefix that is bound to uri in scope, is in the Namespaces()
+// table or is generated; a prefixed name is checked against the declarations in scope.
+// Any namespace declarations that are needed are bound in scope and appended to decls.
+// path locates the name in the map for errors.
+func (e *Encoder) qualify(name string, scope *nsScope, attr bool, decls *string, path *mapPath) (string, error) {
+	if strings.HasPrefix(name, "{") {
+		i := strings.Index(name, "}")
+		if i < 0 {
+			return "", encodeError(ErrInvalidName, path, "invalid Clark-notation name: "+name)
+		}
+		uri, local := name[1:i], name[i+1:]
+		if uri == "" {
+			return local, nil
+		}
+		if p, ok := scope.prefix(uri, !attr); ok {
+			if p == "" {
+				return local, nil
+			}
+			return p + ":" + local, nil
+		}
+		p, ok := e.nsPrefixes[uri]
+		if _, bound := scope.uri(p); !ok || bound {
+			for n := 1; ; n++ {
+				p = "ns" + strconv.Itoa(n)
+				if _, bound := scope.uri(p); !bound {
+					break
+				}
+			}
+		}
+		e.declare(scope, p, uri, decls)
+		return p + ":" + local, nil
+	}
+
+	i := strings.Index(name, ":")
+	if i <= 0 {
+		return name, nil
+	}
+	p := name[:i]
+	if _, ok := scope.uri(p); ok {
+		return name, nil
+	}
+	if uri, ok := e.namespaces[p]; ok {
+		e.declare(scope, p, uri, decls)
+		return name, nil
+	}
+	if e.nsCheck {
+		return "", encodeError(ErrNamespace, path, "namespace prefix is not declared for: "+name)
+	}
+	return name, nil
+}
+
+func (e *Encoder) declare(scope *nsScope, prefix, uri string, decls *string) {
+	scope.bind(prefix, uri)
+	*decls += ` xmlns:` + prefix + `="` + escapeString(uri, true, false) + `"`
+}
@@ -0,0 +1,41 @@
+// j2x_number.go - decoding JSON numbers as json.Number values
+
+package j2x
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// FloatFormat sets the format of float64 and float32 values, as for strconv.FormatFloat();
+// the default is 'g' with precision -1, the same as "%v" formatting.  JSON numbers are
+// decoded as json.Number values, so they are encoded as they are in the source.
+func FloatFormat(fmt byte, prec int) Option {
+	return func(e *Encoder) {
+		if !strings.ContainsRune("bBeEfgGxX", rune(fmt)) {
+			e.optErr = errors.New("invalid float format: " + string(rune(fmt)))
+			return
+		}
+		e.floatFmt = fmt
+		e.floatPrec = prec
+	}
+}
+
+// unmarshalJson is json.Unmarshal() with numbers decoded as json.Number values.
+func unmarshalJson(b []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("invalid data after top-level JSON value")
+	}
+	return nil
+}
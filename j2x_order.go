@@ -0,0 +1,222 @@
+// j2x_order.go - deterministic key ordering for the XML encoders
+
+package j2x
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+)
+
+// KeyOrder selects the order in which map keys are encoded as attributes and child elements.
+type KeyOrder int
+
+const (
+	// SortedKeys encodes attributes and child elements in lexical order of their keys.
+	SortedKeys KeyOrder = iota
+	// JsonKeyOrder encodes attributes and child elements in the order the keys occur in the
+	// source JSON value.  It applies to Encoder.EncodeJson() and Converter - see Ordering();
+	// map[string]interface{} values are still encoded sorted.
+	// Use *OrderedMap values to control the order of maps you build yourself.
+	JsonKeyOrder
+)
+
+// OrderedMap is a map[string]interface{} that remembers the order its keys were set in.
+// MapToXml() and MapToXmlIndent() encode the attributes and child elements of OrderedMap
+// values - at any level of a map[string]interface{} - in that order, which lets you build
+// documents for schemas that require an element sequence.
+// JsonToOrderedMap() decodes JSON objects - including nested objects - as *OrderedMap values.
+type OrderedMap struct {
+	keys []string
+	m    map[string]interface{}
+}
+
+// NewOrderedMap returns an empty OrderedMap.
+func NewOrderedMap() *OrderedMap {
+	return &OrderedMap{m: make(map[string]interface{})}
+}
+
+// Set sets the value for key.  A new key is appended to the key order; an existing key
+// keeps its position.
+func (om *OrderedMap) Set(key string, value interface{}) {
+	if om.m == nil {
+		om.m = make(map[string]interface{})
+	}
+	if _, ok := om.m[key]; !ok {
+		om.keys = append(om.keys, key)
+	}
+	om.m[key] = value
+}
+
+// Get returns the value for key and whether the key is set.
+func (om *OrderedMap) Get(key string) (interface{}, bool) {
+	v, ok := om.m[key]
+	return v, ok
+}
+
+// Delete removes key from the map.
+func (om *OrderedMap) Delete(key string) {
+	if _, ok := om.m[key]; !ok {
+		return
+	}
+	delete(om.m, key)
+	for i, k := range om.keys {
+		if k == key {
+			om.keys = append(om.keys[:i], om.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Keys returns the keys in order.
+func (om *OrderedMap) Keys() []string {
+	return append([]string(nil), om.keys...)
+}
+
+// Len returns the number of keys.
+func (om *OrderedMap) Len() int {
+	return len(om.keys)
+}
+
+// UnmarshalJSON implements json.Unmarshaler; nested objects are decoded as *OrderedMap values.
+func (om *OrderedMap) UnmarshalJSON(b []byte) error {
+	v, err := JsonToOrderedMap(b)
+	if err != nil {
+		return err
+	}
+	*om = *v
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler; keys are encoded in order.
+func (om *OrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range om.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		kb, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(kb)
+		buf.WriteByte(':')
+		vb, err := json.Marshal(om.m[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(vb)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// JsonToOrderedMap decodes a JSON object as an *OrderedMap.  Nested objects are also
+// decoded as *OrderedMap values so that the source key order is kept at every level.
+func JsonToOrderedMap(jsonString []byte) (*OrderedMap, error) {
+	v, err := jsonToOrdered(jsonString)
+	if err != nil {
+		return nil, err
+	}
+	om, ok := v.(*OrderedMap)
+	if !ok {
+		return nil, errors.New("JSON value is not an object")
+	}
+	return om, nil
+}
+
+// jsonToOrdered decodes a JSON value; objects are decoded as *OrderedMap values.
+func jsonToOrdered(jsonString []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(jsonString))
+	dec.UseNumber()
+	v, err := decodeOrdered(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = dec.Token(); err != io.EOF {
+		return nil, errors.New("invalid data after top-level JSON value")
+	}
+	return v, nil
+}
+
+// OrderedMapToXml encodes an *OrderedMap as XML with the elements in key order.
+// See MapToXml() for encoding rules.
+func OrderedMapToXml(om *OrderedMap, rootTag ...string) ([]byte, error) {
+	var b bytes.Buffer
+	err := defaultEncoder(&b, rootTag).Encode(om)
+	return b.Bytes(), err
+}
+
+// OrderedMapToXmlIndent encodes an *OrderedMap as pretty XML with the elements in key order.
+// See MapToXml() for encoding rules.
+func OrderedMapToXmlIndent(om *OrderedMap, prefix, indent string, rootTag ...string) ([]byte, error) {
+	var b bytes.Buffer
+	err := defaultEncoder(&b, rootTag, Indent(prefix, indent)).Encode(om)
+	return b.Bytes(), err
+}
+
+// keysOf returns the map behind a map[string]interface{} or *OrderedMap value and
+// its keys in encoding order; a nil *OrderedMap has no keys.
+func keysOf(v interface{}) (map[string]interface{}, []string) {
+	switch v.(type) {
+	case *OrderedMap:
+		om := v.(*OrderedMap)
+		if om == nil {
+			return nil, nil
+		}
+		return om.m, om.keys
+	case map[string]interface{}:
+		m := v.(map[string]interface{})
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return m, keys
+	}
+	return nil, nil
+}
+
+// decodeOrdered decodes the next JSON value from dec; objects are returned as *OrderedMap.
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	t, err := dec.Token()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	switch t {
+	case json.Delim('{'):
+		om := NewOrderedMap()
+		for dec.More() {
+			t, err = dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := t.(string)
+			val, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			om.Set(key, val)
+		}
+		_, err = dec.Token() // '}'
+		return om, err
+	case json.Delim('['):
+		list := make([]interface{}, 0)
+		for dec.More() {
+			val, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, val)
+		}
+		_, err = dec.Token() // ']'
+		return list, err
+	}
+	return t, nil
+}
@@ -0,0 +1,154 @@
+// j2x_parallel.go - concurrent conversion of a stream of JSON values
+
+package j2x
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// ErrorMode selects how ConvertParallel() handles a value that can't be converted.
+type ErrorMode int
+
+const (
+	// FirstError stops the conversion at the first value that can't be converted.
+	FirstError ErrorMode = iota
+	// CollectErrors skips the values that can't be converted and returns their errors.
+	CollectErrors
+)
+
+// RecordErrors is the error returned by ConvertParallel() in CollectErrors mode.
+type RecordErrors []*RecordError
+
+func (e RecordErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return strconv.Itoa(len(e)) + " records failed; first: " + e[0].Error()
+}
+
+// errStopped is the result of the values that are read after the conversion is stopped.
+var errStopped = errors.New("conversion stopped")
+
+// ConvertParallel reads the JSON values of r, as NewConverter() does, converts them with
+// the given number of goroutines and writes the XML documents to w in the order of the
+// values.  If workers is less than 1, GOMAXPROCS goroutines are used.  The documents are
+// encoded by NewEncoder() with the options, as a Converter encodes them.
+//
+// No more than 2*workers values are read ahead of the document that is being written, so
+// a slow writer holds back the reader.  In FirstError mode the documents of the values
+// before the first error are written and its *RecordError is returned; in CollectErrors mode
+// all the documents that can be converted are written and a RecordErrors value is returned
+// for the others.  A JSON syntax error ends the conversion in either mode.
+func ConvertParallel(r io.Reader, w io.Writer, workers int, mode ErrorMode, opts ...Option) error {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	type result struct {
+		xml []byte
+		err error
+	}
+	type job struct {
+		record *RecordError // locates the value for errors
+		json   []byte
+		res    chan result
+	}
+	jobs := make(chan job)
+	queue := make(chan chan result, 2*workers) // results in the order of the values
+	done := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				var b bytes.Buffer
+				if err := NewEncoder(&b, opts...).EncodeJson(j.json); err != nil {
+					j.record.Err = err
+					j.res <- result{err: j.record}
+					continue
+				}
+				j.res <- result{xml: b.Bytes()}
+			}
+		}()
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		bw := bufio.NewWriter(w)
+		var errs RecordErrors
+		var err error
+		for res := range queue {
+			r := <-res
+			switch {
+			case err != nil:
+				// draining
+			case r.err == nil:
+				if _, err = bw.Write(r.xml); err != nil {
+					close(done)
+				}
+			case mode == CollectErrors && r.err != errStopped:
+				if re, ok := r.err.(*RecordError); ok {
+					errs = append(errs, re)
+					break
+				}
+				fallthrough
+			default:
+				err = r.err
+				close(done)
+			}
+		}
+		if ferr := bw.Flush(); err == nil {
+			err = ferr
+		}
+		if err == nil && len(errs) > 0 {
+			err = errs
+		}
+		errc <- err
+	}()
+
+	f := newFramer(r)
+read:
+	for index := 0; ; index++ {
+		res := make(chan result, 1)
+		select {
+		case queue <- res:
+		case <-done:
+			break read
+		}
+		err := f.skip()
+		offset := f.offset
+		var jb []byte
+		if err == nil {
+			jb, err = f.next()
+		}
+		if err != nil {
+			if err == io.EOF {
+				res <- result{}
+				break
+			}
+			if err == io.ErrUnexpectedEOF || isSyntaxError(err) {
+				err = &RecordError{index, offset, err}
+			}
+			res <- result{err: err}
+			break
+		}
+		select {
+		case jobs <- job{&RecordError{Index: index, Offset: offset}, jb, res}:
+		case <-done:
+			res <- result{err: errStopped}
+			break read
+		}
+	}
+	close(jobs)
+	close(queue)
+	wg.Wait()
+	return <-errc
+}
@@ -3,25 +3,36 @@
 package j2x
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"io"
 )
 
 // JsonReaderToXml implements JsonToXml() by wrapping MapToXml() with an io.Reader.
-// Repeated calls will bulk process the stream of anonymous JSON strings.
+// Repeated calls will bulk process the stream of anonymous JSON strings; see
+// JsonReaderToMap() for how rdr is read.
 // The function returns: XML string, pointer to source JSON value, error.
 func JsonReaderToXml(rdr io.Reader, rootTag ...string) ([]byte, *[]byte, error) {
-	m, jb, err := JsonReaderToMap(rdr)
+	jb, err := getJson(rdr)
 	if err != nil {
 		return nil, jb, err
 	}
-	doc, derr :=  MapToXml(m, rootTag...)
-	return doc, jb, derr
+	var b bytes.Buffer
+	derr := defaultEncoder(&b, rootTag).EncodeJson(*jb)
+	return b.Bytes(), jb, derr
 }
 
-// JsonReaderToMap wraps json.Unmarshal() with an io.Reader.
-// Repeated calls will bulk process the stream of anonymous JSON strings.
+// JsonReaderToMap wraps json.Unmarshal() with an io.Reader; numbers are decoded as
+// json.Number values.
+// Repeated calls will bulk process the stream of anonymous JSON strings, which may be
+// separated by white space - as with NDJSON - or commas.  No more than a value is read
+// from rdr, so if rdr isn't an io.ByteScanner it is read one byte per Read call: wrap an
+// *os.File or a net.Conn in a *bufio.Reader, and pass that reader on each call, or use
+// NewConverter(), which buffers the stream.  If rdr isn't an io.ByteScanner a number or
+// a true, false or null literal must also be followed by white space, a comma or the
+// end of the input.  After a JSON syntax error the rest of the line is skipped.
 // The function returns: map[string]interface{}, pointer to source JSON value, error.
 func JsonReaderToMap(rdr io.Reader) (map[string]interface{}, *[]byte, error) {
 	jb, err := getJson(rdr)
@@ -31,11 +42,26 @@ func JsonReaderToMap(rdr io.Reader) (map[string]interface{}, *[]byte, error) {
 
 	// Unmarshal the 'presumed' JSON string
 	val := make(map[string]interface{}, 0)
-	err = json.Unmarshal(*jb, &val)
+	err = unmarshalJson(*jb, &val)
 	return val, jb, err
 }
 
+// JsonReaderToOrderedMap implements JsonToOrderedMap() with an io.Reader.
+// Repeated calls will bulk process the stream of anonymous JSON strings; see
+// JsonReaderToMap() for how rdr is read.
+// The function returns: *OrderedMap, pointer to source JSON value, error.
+func JsonReaderToOrderedMap(rdr io.Reader) (*OrderedMap, *[]byte, error) {
+	jb, err := getJson(rdr)
+	if err != nil {
+		return nil, jb, err
+	}
+
+	om, err := JsonToOrderedMap(*jb)
+	return om, jb, err
+}
+
 // JsonReaderToStruct - wraps json.Unmarshal to load instances of a structure.
+// See JsonReaderToMap() for how rdr is read.
 // The function returns: pointer to source JSON value, error - structPtr holds the data.
 func JsonReaderToStruct(rdr io.Reader, structPtr interface{}) (*[]byte, error) {
 	jb, err := getJson(rdr)
@@ -47,53 +73,151 @@ func JsonReaderToStruct(rdr io.Reader, structPtr interface{}) (*[]byte, error) {
 	return jb, err
 }
 
+// getJson returns the source of the next JSON value of rdr.  Values may be separated by
+// white space - as with NDJSON - or commas or be concatenated; a byte order mark is skipped.
+// No more than the value is read from rdr.  If rdr is not an io.ByteScanner, such as
+// *bufio.Reader or *bytes.Reader, it is read one byte at a time and a number or a true,
+// false or null literal must be followed by white space, a comma or the end of the input.
+// After a syntax error the rest of the line is skipped, so that the next call resumes with
+// the next line rather than inside the invalid value.
 func getJson(rdr io.Reader) (*[]byte, error) {
-	bval := make([]byte, 1)
-	jb := make([]byte, 0)
-	var inQuote, inJson bool
-	var parenCnt int
+	f := &framer{r: byteScanner(rdr)}
+	jb, err := f.next()
+	if err != nil {
+		if isSyntaxError(err) {
+			f.skipLine()
+		}
+		return nil, err
+	}
+	return &jb, nil
+}
+
+// byteScanner returns rdr if it is an io.ByteScanner, otherwise a byteReader for it.
+func byteScanner(rdr io.Reader) io.ByteScanner {
+	if bs, ok := rdr.(io.ByteScanner); ok {
+		return bs
+	}
+	return &byteReader{r: rdr}
+}
+
+// byteReader reads one byte at a time from r, so it doesn't read beyond the bytes that
+// are used.  A byte that is unread is lost if the byteReader isn't read again.
+type byteReader struct {
+	r      io.Reader
+	b      [1]byte
+	unread bool
+}
 
-	// scan the input for a matched set of {...}
-	// json.Unmarshal will handle syntax checking.
+func (br *byteReader) ReadByte() (byte, error) {
+	if br.unread {
+		br.unread = false
+		return br.b[0], nil
+	}
 	for {
-		_, err := rdr.Read(bval)
+		n, err := br.r.Read(br.b[:])
+		if n == 1 {
+			return br.b[0], nil
+		}
 		if err != nil {
-			if err == io.EOF && inJson && parenCnt > 0 {
-				return nil, errors.New("no closing } for JSON string: "+string(jb))
-			}
-			return nil, err
+			return 0, err
 		}
-		switch bval[0] {
-		case '{':
-			if !inQuote {
-				parenCnt++
-				inJson = true
-			}
-		case '}':
-			if !inQuote {
-				parenCnt--
-			}
-			if parenCnt < 0 {
-				return nil, errors.New("closing } without opening {: "+string(jb))
-			}
-		case '"':
-			if inQuote {
-				inQuote = false
-			} else {
-				inQuote = true
-			}
-		case '\n', '\r', '\t', ' ':
-			if !inQuote {
-				continue
-			}
+	}
+}
+
+func (br *byteReader) UnreadByte() error {
+	br.unread = true
+	return nil
+}
+
+// newFramer returns a framer for r, which it buffers if r isn't an io.ByteScanner.
+func newFramer(r io.Reader) *framer {
+	bs, ok := r.(io.ByteScanner)
+	if !ok {
+		bs = bufio.NewReader(r)
+	}
+	return &framer{r: bs}
+}
+
+// framer reads the source of JSON values with a json.Decoder.  It reads one byte at a time
+// from r, so the decoder doesn't read beyond the end of a value.
+type framer struct {
+	r      io.ByteScanner
+	offset int64 // of the next byte of r
+	last   byte  // the last byte read by the decoder
+}
+
+func (f *framer) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	c, err := f.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	f.offset++
+	f.last = c
+	p[0] = c
+	return 1, nil
+}
+
+// skipLine reads up to and including the next newline, unless the decoder stopped at one.
+func (f *framer) skipLine() {
+	if f.last == '\n' {
+		return
+	}
+	for {
+		c, err := f.r.ReadByte()
+		if err != nil {
+			return
 		}
-		if inJson {
-			jb = append(jb, bval...)
-			if parenCnt == 0 {
-				break
-			}
+		f.offset++
+		if c == '\n' {
+			return
 		}
 	}
+}
 
-	return &jb, nil
+// next returns the source of the next JSON value, or io.EOF if there is none.
+func (f *framer) next() ([]byte, error) {
+	if err := f.skip(); err != nil {
+		return nil, err
+	}
+	var raw json.RawMessage
+	dec := json.NewDecoder(f)
+	if err := dec.Decode(&raw); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	// the end of a number, string or literal is only known from the byte after it
+	if n, _ := dec.Buffered().Read(make([]byte, 1)); n > 0 {
+		f.r.UnreadByte()
+		f.offset--
+	}
+	return raw, nil
+}
+
+// skip reads the white space, commas and byte order marks before a value.
+func (f *framer) skip() error {
+	for {
+		c, err := f.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		f.offset++
+		switch c {
+		case ' ', '\t', '\r', '\n', ',':
+		case 0xEF: // UTF-8 byte order mark
+			b, _ := f.r.ReadByte()
+			c, _ := f.r.ReadByte()
+			f.offset += 2
+			if b != 0xBB || c != 0xBF {
+				return errors.New("invalid byte order mark")
+			}
+		default:
+			f.offset--
+			return f.r.UnreadByte()
+		}
+	}
 }
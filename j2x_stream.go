@@ -0,0 +1,690 @@
+// j2x_stream.go - token-driven JSON to XML transcoding with bounded memory
+
+package j2x
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DefaultMaxBuffer is the default number of bytes of XML that Transcode() holds back.
+const DefaultMaxBuffer = 64 << 10
+
+// MaxBuffer sets the number of bytes of XML that Transcode() holds back while start tags
+// are still open for attributes; the default is DefaultMaxBuffer.  With 0 a start tag is
+// written as soon as an element's content follows it.
+func MaxBuffer(n int) Option {
+	return func(e *Encoder) {
+		if n < 0 {
+			e.optErr = errors.New("negative buffer size")
+			return
+		}
+		e.maxBuffer = n
+	}
+}
+
+// JsonStreamToXml transcodes the JSON value read from rdr to XML written to wtr without
+// decoding it into a map.  See Encoder.Transcode().
+func JsonStreamToXml(rdr io.Reader, wtr io.Writer, rootTag ...string) error {
+	return defaultEncoder(wtr, rootTag).Transcode(rdr)
+}
+
+// Transcode reads a JSON value from r token by token and writes its XML encoding as it
+// goes, so a document of any size is encoded in bounded memory.  The XML is the same as
+// Encode() with the JsonKeyOrder ordering writes for the decoded value.  r should hold a
+// single JSON value; input after it may be consumed.
+//
+// As JSON object members are encoded in the order they are read, an attribute key may
+// follow child elements whose XML has been encoded after the element's start tag.  So
+// the XML of an element is held back from the start of its start tag until the element
+// ends, when the start tag is completed with all its attributes.  If the held XML
+// grows beyond the MaxBuffer() size, it is written out with the start tags completed
+// with the attributes read so far; an attribute key of such an element that is read
+// later is an *EncodeError with Kind ErrBufferLimit.
+//
+// Likewise the XML of the first member of a top-level object is held back - its key is
+// the root element only if it is the single member.  If the member exceeds the buffer
+// limit, its key is used as the root element and any further top-level key is an
+// ErrBufferLimit error.  Setting RootTag() avoids that.
+//
+// Namespace declarations are in scope for the keys that are read after them: a
+// "-xmlns:prefix" key must precede the keys of the elements it contains that use the
+// prefix.  A namespace for a Clark-notation attribute key that follows child elements
+// may be declared on them as well as on the element.
+//
+// If there is an error the XML that was encoded before it may have been written.
+func (e *Encoder) Transcode(r io.Reader) (err error) {
+	if e.optErr != nil {
+		return e.optErr
+	}
+
+	w, flush := e.writer()
+	defer func() {
+		if ferr := flush(); err == nil {
+			err = ferr
+		}
+	}()
+	w.WriteString(e.decl)
+
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	t := &transcoder{e: e, dec: dec, w: w}
+	tag := e.rootTag
+	if tag == "" {
+		tag = DefaultRootTag
+	}
+	switch tok {
+	case json.Delim('['):
+		err = t.items(tag, e.itemTag, e.prefix, nil, nil)
+	case json.Delim('{'):
+		err = t.root()
+	default:
+		err = e.mapToXml(&t.held, tag, tok, e.prefix, nil, nil)
+	}
+	if err != nil {
+		return err
+	}
+	t.undecided = false
+	t.held.Write(t.epilog.Bytes())
+	return t.written()
+}
+
+// transcoder holds the state of Encoder.Transcode().
+type transcoder struct {
+	e     *Encoder
+	dec   *json.Decoder
+	w     xmlWriter
+	held  bytes.Buffer // XML that is held back
+	stack []*frame     // open elements
+
+	// tokens to read before those of dec - the first member of a top-level object
+	// is read again if it isn't the root element
+	pending   []json.Token
+	undecided bool // the first member of a top-level object is being encoded as the root
+	log       []json.Token
+	logSize   int
+
+	epilog bytes.Buffer // markup written after the root element
+
+	// the DOCTYPE declaration before the root element, which is checked once the root
+	// tag is known
+	doctype     json.Token
+	doctypePath *mapPath
+}
+
+// frame is an element that is being encoded.
+type frame struct {
+	name      string // validated key
+	keyAttr   string
+	decls     string // namespace declarations
+	tag       string // qualified name once the start tag is complete
+	attrs     []frameAttr
+	pad       string
+	newline   string
+	scope     *nsScope
+	path      *mapPath
+	pos       int  // offset of the start tag in held
+	committed bool // the start tag has been completed
+	children  bool
+	text      bool
+	textKey   string // the key of the text
+}
+
+type frameAttr struct {
+	name  string // validated name
+	value string
+	path  *mapPath
+}
+
+func (t *transcoder) next() (json.Token, error) {
+	var tok json.Token
+	if len(t.pending) > 0 {
+		tok, t.pending = t.pending[0], t.pending[1:]
+	} else {
+		var err error
+		if tok, err = t.dec.Token(); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+	}
+	if t.undecided {
+		t.log = append(t.log, tok)
+		t.logSize += 8
+		if s, ok := tok.(string); ok {
+			t.logSize += len(s)
+		}
+		if t.logSize > t.e.maxBuffer {
+			t.decide()
+		}
+	}
+	return tok, nil
+}
+
+// decide stops holding back the first member of a top-level object; its key is the root.
+func (t *transcoder) decide() {
+	t.undecided = false
+	t.log = nil
+}
+
+// root encodes a top-level object.  Like Encoder.rootElem() the key of a single member is
+// the root element unless the value is a list that isn't wrapped or the key is a member of
+// an element.  Markup keys that precede
+// the first key of an element are written before the root element, the others after it.
+func (t *transcoder) root() error {
+	e := t.e
+	var tok json.Token
+	var key string
+	for {
+		var err error
+		if tok, err = t.next(); err != nil {
+			return err
+		}
+		var ok bool
+		if key, ok = tok.(string); !ok || !isMarkup(key) {
+			break
+		}
+		v, err := t.next()
+		if err != nil {
+			return err
+		}
+		path := &mapPath{key: key, index: -1}
+		if err := t.markup(&t.held, key, v, e.prefix, true, path); err != nil {
+			return err
+		}
+		if key == doctypeKey {
+			t.doctype, t.doctypePath = v, path
+		}
+		if err := t.written(); err != nil {
+			return err
+		}
+	}
+	if e.rootTag != "" {
+		t.pending = append(t.pending, tok)
+		return t.rootObject(e.rootTag)
+	}
+	if tok == json.Delim('}') || e.isMember(key) { // empty object, or not a root element
+		t.pending = append(t.pending, tok)
+		return t.rootObject(DefaultRootTag)
+	}
+	v, err := t.next()
+	if err != nil {
+		return err
+	}
+	if v == json.Delim('[') && e.itemNamer == nil {
+		t.pending = append(t.pending, key, v)
+		return t.rootObject(DefaultRootTag)
+	}
+
+	t.undecided = true
+	t.log = []json.Token{key, v}
+	if err := t.elem(key, v, e.prefix, nil, &mapPath{key: key, index: -1}); err != nil {
+		return err
+	}
+	for {
+		if tok, err = t.next(); err != nil || tok == json.Delim('}') {
+			t.decide()
+			if err == nil {
+				err = t.checkDoctype(key)
+			}
+			return err
+		}
+		k, _ := tok.(string)
+		if !isMarkup(k) {
+			break
+		}
+		// the markup follows the root element and isn't read again with the root tag
+		n := len(t.log) - 1
+		if v, err = t.next(); err != nil {
+			return err
+		}
+		if err := t.markup(&t.epilog, k, v, e.prefix, false, &mapPath{key: k, index: -1}); err != nil {
+			return err
+		}
+		if t.undecided {
+			t.log = t.log[:n]
+		}
+	}
+	if !t.undecided {
+		return encodeError(ErrBufferLimit, &mapPath{key: fmt.Sprint(tok), index: -1},
+			"top-level key follows a root element that exceeds the buffer limit")
+	}
+	// encode the object again with the root tag
+	t.pending = t.log
+	t.decide()
+	t.held.Reset()
+	t.stack = t.stack[:0]
+	return t.rootObject(DefaultRootTag)
+}
+
+// rootObject encodes the rest of a top-level object as the root element tagged tag.
+func (t *transcoder) rootObject(tag string) error {
+	if err := t.checkDoctype(tag); err != nil {
+		return err
+	}
+	return t.object(tag, t.e.prefix, nil, nil)
+}
+
+// checkDoctype checks that the DOCTYPE declaration, if there is one, names the root element
+// tagged tag.
+func (t *transcoder) checkDoctype(tag string) error {
+	if t.doctype == nil {
+		return nil
+	}
+	return t.e.checkDoctype(t.doctype, tag, t.doctypePath)
+}
+
+// elem encodes the value that starts with tok as element(s) tagged key.  Like mapToXml()
+// a list is encoded as an element for each value, unless it is wrapped.
+func (t *transcoder) elem(key string, tok json.Token, pad string, scope *nsScope, path *mapPath) error {
+	e := t.e
+	switch tok {
+	case json.Delim('['):
+		if e.itemNamer != nil {
+			return t.items(key, e.itemNamer(key), pad, scope, path)
+		}
+		for i := 0; ; i++ {
+			tok, err := t.next()
+			if err != nil {
+				return err
+			}
+			switch tok {
+			case json.Delim(']'):
+				return nil
+			case json.Delim('['):
+				// a list in a list is encoded as an element with item elements
+				err = t.items(key, e.itemTagOf(key), pad, scope, &mapPath{path, "", i})
+			default:
+				err = t.elem(key, tok, pad, scope, &mapPath{path, "", i})
+			}
+			if err != nil {
+				return err
+			}
+		}
+	case json.Delim('{'):
+		return t.object(key, pad, scope, path)
+	}
+	if err := e.mapToXml(&t.held, key, tok, pad, scope, path); err != nil {
+		return err
+	}
+	return t.written()
+}
+
+// items encodes a list as an element tagged tag with an element tagged itemTag for
+// each value.
+func (t *transcoder) items(tag, itemTag string, pad string, scope *nsScope, path *mapPath) error {
+	e := t.e
+	f, err := t.open(tag, pad, scope, path)
+	if err != nil {
+		return err
+	}
+	for i := 0; ; i++ {
+		tok, err := t.next()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim(']'):
+			return t.close(f)
+		case json.Delim('['):
+			f.children = true
+			err = t.items(itemTag, e.itemTagOf(itemTag), pad+e.indent, f.scope, &mapPath{path, "", i})
+		default:
+			f.children = true
+			err = t.elem(itemTag, tok, pad+e.indent, f.scope, &mapPath{path, "", i})
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// object encodes the members of an object, up to the closing '}', as an element tagged key.
+func (t *transcoder) object(key string, pad string, scope *nsScope, path *mapPath) error {
+	e := t.e
+	f, err := t.open(key, pad, scope, path)
+	if err != nil {
+		return err
+	}
+	for {
+		tok, err := t.next()
+		if err != nil {
+			return err
+		}
+		if tok == json.Delim('}') {
+			return t.close(f)
+		}
+		k, _ := tok.(string)
+		v, err := t.next()
+		if err != nil {
+			return err
+		}
+		switch {
+		case e.isAttr(k):
+			err = t.attr(f, k, v)
+		case isMarkup(k) && path == nil:
+			// a member of a top-level object that isn't the root element
+			err = t.markup(&t.epilog, k, v, e.prefix, false, &mapPath{key: k, index: -1})
+		case isMarkup(k):
+			if f.text {
+				return textKeyError(f, k, path)
+			}
+			f.children = true
+			if err = t.markup(&t.held, k, v, pad+e.indent, false, &mapPath{path, k, -1}); err == nil {
+				err = t.written()
+			}
+		case k == e.contentKey && e.contentKey != "":
+			if f.children || f.text {
+				return textKeyError(f, k, path)
+			}
+			f.text, f.textKey = true, k
+			err = t.content(f, v, &mapPath{path, k, -1})
+		case k != "" && (k == e.textKey || k == e.cdataKey):
+			if f.children || f.text {
+				return textKeyError(f, k, path)
+			}
+			if _, ok := v.(json.Delim); ok {
+				return encodeError(ErrTextKey, &mapPath{path, k, -1}, k+" value is an object or list")
+			}
+			f.text, f.textKey = true, k
+			var s string
+			if s, err = e.textValue(v, &mapPath{path, k, -1}); err == nil {
+				e.writeText(&t.held, s, k == e.cdataKey)
+				err = t.written()
+			}
+		default:
+			if f.text {
+				return textKeyError(f, k, path)
+			}
+			f.children = true
+			err = t.elem(k, v, pad+e.indent, f.scope, &mapPath{path, k, -1})
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// content encodes the value of the content key of f, which starts with tok, as mixed
+// content.  See Encoder.writeContent().
+func (t *transcoder) content(f *frame, tok json.Token, path *mapPath) error {
+	e := t.e
+	t.e = e.inline()
+	defer func() { t.e = e }()
+	if tok != json.Delim('[') {
+		return t.contentItem(f, tok, path)
+	}
+	for i := 0; ; i++ {
+		tok, err := t.next()
+		if err != nil {
+			return err
+		}
+		if tok == json.Delim(']') {
+			return nil
+		}
+		if err := t.contentItem(f, tok, &mapPath{path, "", i}); err != nil {
+			return err
+		}
+	}
+}
+
+// contentItem encodes an item of mixed content, which starts with tok.
+func (t *transcoder) contentItem(f *frame, tok json.Token, path *mapPath) error {
+	e := t.e
+	switch tok {
+	case json.Delim('['):
+		return encodeError(ErrTextKey, path, e.contentKey+" item is a list")
+	case json.Delim('{'):
+		for {
+			tok, err := t.next()
+			if err != nil {
+				return err
+			}
+			if tok == json.Delim('}') {
+				return nil
+			}
+			k, _ := tok.(string)
+			v, err := t.next()
+			if err != nil {
+				return err
+			}
+			kpath := &mapPath{path, k, -1}
+			switch {
+			case isMarkup(k):
+				err = t.markup(&t.held, k, v, "", false, kpath)
+			case k != "" && (k == e.textKey || k == e.cdataKey):
+				if _, ok := v.(json.Delim); ok {
+					return encodeError(ErrTextKey, kpath, k+" value is an object or list")
+				}
+				var s string
+				if s, err = e.textValue(v, kpath); err == nil {
+					e.writeText(&t.held, s, k == e.cdataKey)
+				}
+			case e.isAttr(k):
+				return encodeError(ErrTextKey, kpath, fmt.Sprintf("attribute key in %s item", e.contentKey))
+			default:
+				err = t.elem(k, v, "", f.scope, kpath)
+			}
+			if err == nil {
+				err = t.written()
+			}
+			if err != nil {
+				return err
+			}
+		}
+	case nil:
+		return nil
+	}
+	s, err := e.textValue(tok, path)
+	if err != nil {
+		return err
+	}
+	e.writeText(&t.held, s, false)
+	return t.written()
+}
+
+// markup reads the value of the markup key k, which starts with tok, and writes the markup
+// to w.  See Encoder.writeMarkup().
+func (t *transcoder) markup(w xmlWriter, k string, tok json.Token, pad string, doctype bool, path *mapPath) error {
+	var err error
+	var v interface{} = tok
+	if tok == json.Delim('[') {
+		var list []interface{}
+		for {
+			if tok, err = t.next(); err != nil {
+				return err
+			}
+			if tok == json.Delim(']') {
+				break
+			}
+			if _, ok := tok.(json.Delim); ok {
+				return encodeError(ErrMarkup, &mapPath{path, "", len(list)}, k+" value is an object or list")
+			}
+			list = append(list, tok)
+		}
+		v = list
+	} else if _, ok := tok.(json.Delim); ok {
+		return encodeError(ErrMarkup, path, k+" value is an object")
+	}
+	return t.e.writeMarkup(w, k, v, pad, doctype, path)
+}
+
+// textKeyError is the error for the key k of f, an element with text or child elements.
+func textKeyError(f *frame, k string, path *mapPath) error {
+	if f.text {
+		k = f.textKey
+	}
+	return encodeError(ErrTextKey, &mapPath{path, k, -1}, k+" key occurs with other non-attribute keys")
+}
+
+// attr adds the attribute key k with the value v to the start tag of f.
+func (t *transcoder) attr(f *frame, k string, v json.Token) error {
+	path := &mapPath{f.path, k, -1}
+	value, ok := t.e.formatValue(v)
+	switch {
+	case v == json.Delim('['):
+		return encodeError(ErrAttrValue, path, "[]interface {}")
+	case v == json.Delim('{'):
+		return encodeError(ErrAttrValue, path, "map[string]interface {}")
+	case !ok:
+		return encodeError(ErrAttrValue, path, fmt.Sprintf("%T", v))
+	}
+	if f.committed {
+		return encodeError(ErrBufferLimit, path, "attribute follows element content that exceeds the buffer limit")
+	}
+	name := k[len(t.e.attrPrefix):]
+	if isNsDecl(name) {
+		if _, ok := f.scope.decl[nsDeclPrefix(name)]; ok {
+			return encodeError(ErrNamespace, path, "namespace prefix is declared after it is used: "+name)
+		}
+		if uri, ok := v.(string); ok {
+			f.scope.bind(nsDeclPrefix(name), uri)
+		}
+	} else {
+		var err error
+		if name, _, err = t.e.validName(name, true, path); err != nil {
+			return err
+		}
+		if strings.HasPrefix(name, "{") {
+			if name, err = t.e.qualify(name, f.scope, true, &f.decls, path); err != nil {
+				return err
+			}
+		}
+	}
+	f.attrs = append(f.attrs, frameAttr{name, value, path})
+	return nil
+}
+
+// open starts an element tagged key; its start tag is completed by close() or written().
+func (t *transcoder) open(key string, pad string, scope *nsScope, path *mapPath) (*frame, error) {
+	name, keyAttr, err := t.e.validName(key, false, path)
+	if err != nil {
+		return nil, err
+	}
+	f := &frame{
+		name:    name,
+		keyAttr: keyAttr,
+		pad:     pad,
+		newline: t.e.newline(),
+		scope:   &nsScope{parent: scope},
+		path:    path,
+		pos:     t.held.Len(),
+	}
+	// a Clark-notation name is qualified now, so the elements it contains are in its scope
+	if strings.HasPrefix(name, "{") {
+		if f.name, err = t.e.qualify(name, f.scope, false, &f.decls, path); err != nil {
+			return nil, err
+		}
+	}
+	t.stack = append(t.stack, f)
+	return f, nil
+}
+
+// close ends the innermost element, f.
+func (t *transcoder) close(f *frame) error {
+	t.stack = t.stack[:len(t.stack)-1]
+	if !f.committed {
+		if err := t.commit(f); err != nil {
+			return err
+		}
+	}
+	if f.children || f.text {
+		if f.children {
+			t.held.WriteString(f.pad)
+		}
+		t.held.WriteString("</" + f.tag + ">" + f.newline)
+	}
+	return t.written()
+}
+
+// commit completes the start tag of f and inserts it at f.pos in held.  Without content
+// the element is encoded as an empty element.
+func (t *transcoder) commit(f *frame) error {
+	e := t.e
+	decls := f.decls
+	tag, err := e.qualify(f.name, f.scope, false, &decls, f.path)
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(f.attrs))
+	seen := e.newAttrSet(f.keyAttr)
+	for i, a := range f.attrs {
+		names[i] = a.name
+		if !isNsDecl(a.name) {
+			if names[i], err = e.qualify(a.name, f.scope, true, &decls, a.path); err != nil {
+				return err
+			}
+		}
+		if err = seen.add(names[i], a.path); err != nil {
+			return err
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString(f.pad + "<" + tag + decls + f.keyAttr)
+	for i, a := range f.attrs {
+		b.WriteString(" " + names[i] + `="`)
+		e.writeEscaped(&b, a.value, true)
+		b.WriteByte('"')
+	}
+	switch {
+	case f.children:
+		b.WriteString(">" + f.newline)
+	case f.text:
+		b.WriteByte('>')
+	case e.goEmptyElem:
+		b.WriteString("></" + tag + ">" + f.newline)
+	default:
+		b.WriteString("/>" + f.newline)
+	}
+
+	// insert the start tag
+	n := t.held.Len()
+	t.held.Write(b.Bytes())
+	held := t.held.Bytes()
+	copy(held[f.pos+b.Len():], held[f.pos:n])
+	copy(held[f.pos:], b.Bytes())
+
+	f.tag = tag
+	f.committed = true
+	return nil
+}
+
+// written writes out the held XML if no start tag is open and the root is decided, or if
+// it exceeds the buffer limit.  In that case the open start tags of elements that have
+// content are completed.
+func (t *transcoder) written() error {
+	open := len(t.stack)
+	for open > 0 && !t.stack[open-1].committed {
+		open--
+	}
+	if open < len(t.stack) || t.undecided {
+		if t.held.Len() <= t.e.maxBuffer {
+			return nil
+		}
+		// from the innermost element, so the offsets of the enclosing elements are valid
+		for i := len(t.stack) - 1; i >= open; i-- {
+			if f := t.stack[i]; f.children || f.text {
+				if err := t.commit(f); err != nil {
+					return err
+				}
+			}
+		}
+		t.decide()
+	}
+	t.w.Write(t.held.Bytes())
+	t.held.Reset()
+	for _, f := range t.stack[open:] {
+		f.pos = 0
+	}
+	return nil
+}
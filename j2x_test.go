@@ -1,6 +1,7 @@
 package j2x
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"testing"
@@ -248,3 +249,106 @@ func TestEmptyElementSyntax( t *testing.T) {
 	}
 	fmt.Println("v:",string(v))
 }
+
+func TestEscape(t *testing.T) {
+	var tests = []struct {
+		val  interface{}
+		text string
+		attr string
+	}{
+		{`a < b & c > "d" 'e'`, `a &lt; b &amp; c &gt; &quot;d&quot; &apos;e&apos;`, `a &lt; b &amp; c &gt; &quot;d&quot; &apos;e&apos;`},
+		{"tab\tline\nret\r", "tab\tline\nret&#xD;", "tab&#x9;line&#xA;ret&#xD;"},
+		{"nul\x00 bell\x07 bad\xff", "nul\uFFFD bell\uFFFD bad\uFFFD", "nul\uFFFD bell\uFFFD bad\uFFFD"},
+		{[]byte(`<&>`), `&lt;&amp;&gt;`, `&lt;&amp;&gt;`},
+		{float64(3.5), "3.5", "3.5"},
+		{float32(2.5), "2.5", "2.5"},
+		{true, "true", "true"},
+		{int(-1), "-1", "-1"},
+		{int32(32), "32", "32"},
+		{int64(64), "64", "64"},
+	}
+
+	fmt.Println("\nTestEscape ...")
+	for _, tt := range tests {
+		v, err := MapToXml(map[string]interface{}{"elem": tt.val})
+		if err != nil {
+			t.Error("err:", err.Error())
+		}
+		if want := "<elem>" + tt.text + "</elem>"; string(v) != want {
+			t.Errorf("text: %#v got %s want %s", tt.val, v, want)
+		}
+
+		v, err = MapToXml(map[string]interface{}{"elem": map[string]interface{}{"-attr": tt.val}})
+		if err != nil {
+			t.Error("err:", err.Error())
+		}
+		if want := `<elem attr="` + tt.attr + `"/>`; string(v) != want {
+			t.Errorf("attr: %#v got %s want %s", tt.val, v, want)
+		}
+
+		v, err = MapToXml(map[string]interface{}{"elem": map[string]interface{}{"#text": tt.val}})
+		if err != nil {
+			t.Error("err:", err.Error())
+		}
+		if _, ok := tt.val.([]byte); !ok {
+			if want := "<elem>" + tt.text + "</elem>"; string(v) != want {
+				t.Errorf("#text: %#v got %s want %s", tt.val, v, want)
+			}
+		}
+		fmt.Println("v:", string(v))
+	}
+
+	s := `{ "doc":{ "-attr":"\"><inject/>", "#text":"</doc><inject/>" } }`
+	v, err := JsonToXml([]byte(s))
+	if err != nil {
+		t.Error("err:", err.Error())
+	}
+	if want := `<doc attr="&quot;&gt;&lt;inject/&gt;">&lt;/doc&gt;&lt;inject/&gt;</doc>`; string(v) != want {
+		t.Errorf("JsonToXml: got %s want %s", v, want)
+	}
+}
+
+func TestTopLevelValues(t *testing.T) {
+	var tests = []struct {
+		json    string
+		rootTag []string
+		want    string
+	}{
+		{`[ { "a":1 }, { "a":2 } ]`, nil, `<doc><item><a>1</a></item><item><a>2</a></item></doc>`},
+		{`[ 1, "two", null ]`, []string{"list"}, `<list><item>1</item><item>two</item><item/></list>`},
+		{`[]`, nil, `<doc/>`},
+		{`"hello"`, nil, `<doc>hello</doc>`},
+		{`3.5`, []string{"num"}, `<num>3.5</num>`},
+		{`true`, nil, `<doc>true</doc>`},
+		{`null`, nil, `<doc/>`},
+	}
+
+	fmt.Println("\nTestTopLevelValues ...")
+	for _, tt := range tests {
+		v, err := JsonToXml([]byte(tt.json), tt.rootTag...)
+		if err != nil {
+			t.Error("err:", err.Error())
+		}
+		fmt.Println("v:", string(v))
+		if string(v) != tt.want {
+			t.Errorf("%s: got %s want %s", tt.json, v, tt.want)
+		}
+	}
+
+	v, err := JsonToXmlIndent([]byte(`[ { "a":1 }, 2 ]`), "", "  ")
+	if err != nil {
+		t.Error("err:", err.Error())
+	}
+	fmt.Printf("v:\n%s", string(v))
+	if want := "<doc>\n  <item>\n    <a>1</a>\n  </item>\n  <item>2</item>\n</doc>\n"; string(v) != want {
+		t.Errorf("indent: got %q want %q", v, want)
+	}
+
+	var b bytes.Buffer
+	if err = NewEncoder(&b, ItemTag("row"), Ordering(JsonKeyOrder)).EncodeJson([]byte(`[ { "b":1, "a":2 } ]`)); err != nil {
+		t.Error("err:", err.Error())
+	}
+	if want := `<doc><row><b>1</b><a>2</a></row></doc>`; b.String() != want {
+		t.Errorf("ItemTag: got %s want %s", b.String(), want)
+	}
+}
@@ -0,0 +1,193 @@
+// j2x_value.go - encoding scalar values as character data
+
+package j2x
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// TimeLayout sets the layout of time.Time values, as for time.Time.Format(); the default
+// is time.RFC3339Nano, as encoding/xml uses.
+func TimeLayout(layout string) Option {
+	return func(e *Encoder) {
+		e.timeLayout = layout
+	}
+}
+
+// ValuePolicy selects how values that can't be encoded - xml.Marshal() returns an error for
+// them - are handled.
+type ValuePolicy int
+
+const (
+	// ValueError returns an *EncodeError with Kind ErrMarshal; the default.
+	ValueError ValuePolicy = iota
+	// ValueSkip omits the element.
+	ValueSkip
+	// ValuePlaceholder encodes the placeholder text as the element value.  See Placeholder().
+	ValuePlaceholder
+	// ValueFallback encodes the text returned by the fallback function as the element value.
+	// See Fallback().
+	ValueFallback
+)
+
+// FallbackFunc returns the text to encode for the value v that can't be encoded; path
+// locates v in the map as for EncodeError.  If it returns an error, an *EncodeError with
+// Kind ErrMarshal is returned.
+type FallbackFunc func(path string, v interface{}) (string, error)
+
+// Unencodable sets the policy for values that can't be encoded.  Attribute values that
+// can't be encoded are always an error.
+func Unencodable(p ValuePolicy) Option {
+	return func(e *Encoder) {
+		e.valuePolicy = p
+	}
+}
+
+// Placeholder sets the text for the ValuePlaceholder policy; the default is "UNKNOWN".
+func Placeholder(text string) Option {
+	return func(e *Encoder) {
+		e.placeholder = text
+	}
+}
+
+// Fallback sets the ValueFallback policy with the function f.
+func Fallback(f FallbackFunc) Option {
+	return func(e *Encoder) {
+		e.valuePolicy = ValueFallback
+		e.fallback = f
+	}
+}
+
+// unencodable applies the Unencodable() policy to the value v that can't be encoded
+// because of err.  It returns the value to encode instead or skip if the element is omitted.
+func (e *Encoder) unencodable(v interface{}, err error, path *mapPath) (value interface{}, skip bool, rerr error) {
+	switch e.valuePolicy {
+	case ValueSkip:
+		return nil, true, nil
+	case ValuePlaceholder:
+		return e.placeholder, false, nil
+	case ValueFallback:
+		if e.fallback != nil {
+			s, ferr := e.fallback(path.String(), v)
+			if ferr == nil {
+				return s, false, nil
+			}
+			err = ferr
+		}
+	}
+	return nil, false, encodeError(ErrMarshal, path, fmt.Sprintf("%T: %v", v, err))
+}
+
+// formatValue returns the character data for a scalar value: a string, []byte, boolean or
+// numeric value, a time.Time, encoding.TextMarshaler or fmt.Stringer value, a value whose
+// type is defined as a string, boolean or numeric type, or a non-nil pointer to one of them.
+// ok is false for other types.
+func (e *Encoder) formatValue(v interface{}) (s string, ok bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case []byte: // similar to how xml.Marshal handles []byte structure members
+		return string(v), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case json.Number:
+		return string(v), true
+	case float64:
+		return strconv.FormatFloat(v, e.floatFmt, e.floatPrec, 64), true
+	case float32:
+		return strconv.FormatFloat(float64(v), e.floatFmt, e.floatPrec, 32), true
+	case int:
+		return strconv.Itoa(v), true
+	case int8:
+		return strconv.FormatInt(int64(v), 10), true
+	case int16:
+		return strconv.FormatInt(int64(v), 10), true
+	case int32:
+		return strconv.FormatInt(int64(v), 10), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), true
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10), true
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), true
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case *big.Int:
+		if v != nil {
+			return v.String(), true
+		}
+	case *big.Float:
+		if v != nil {
+			return v.Text('g', -1), true
+		}
+		return "", false
+	case complex64:
+		return strconv.FormatComplex(complex128(v), e.floatFmt, e.floatPrec, 64), true
+	case complex128:
+		return strconv.FormatComplex(v, e.floatFmt, e.floatPrec, 128), true
+	case time.Time:
+		return v.Format(e.timeLayout), true
+	case encoding.TextMarshaler:
+		if b, err := v.MarshalText(); err == nil {
+			return string(b), true
+		}
+		return "", false
+	case fmt.Stringer:
+		return v.String(), true
+	case nil:
+		return "", false
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), true
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), e.floatFmt, e.floatPrec, rv.Type().Bits()), true
+	case reflect.Complex64, reflect.Complex128:
+		return strconv.FormatComplex(rv.Complex(), e.floatFmt, e.floatPrec, rv.Type().Bits()), true
+	case reflect.Ptr:
+		if !rv.IsNil() {
+			return e.formatValue(rv.Elem().Interface())
+		}
+	}
+	return "", false
+}
+
+// textValue returns the character data for the value of a text key at path; null is empty.
+// An object or list is an *EncodeError with Kind ErrTextKey, and the Unencodable() policy
+// applies to other values that formatValue() doesn't handle - if the value is skipped,
+// there is no character data.
+func (e *Encoder) textValue(v interface{}, path *mapPath) (string, error) {
+	switch v.(type) {
+	case nil:
+		return "", nil
+	case map[string]interface{}, *OrderedMap, []interface{}:
+		return "", encodeError(ErrTextKey, path, path.key+" value is an object or list")
+	}
+	if s, ok := e.formatValue(v); ok {
+		return s, nil
+	}
+	value, skip, err := e.unencodable(v, errors.New("not a text value"), path)
+	if err != nil || skip {
+		return "", err
+	}
+	return e.textValue(value, path)
+}
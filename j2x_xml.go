@@ -0,0 +1,246 @@
+// j2x_xml.go - decoding XML docs as map[string]interface{} values, the inverse of MapToXml
+
+package j2x
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// XmlToMap decodes an XML doc as a map[string]interface{} value that MapToXml() encodes as
+// an equivalent XML doc.  The following rules apply.
+//   - The map has a single key, the tag of the root element.
+//   - Attributes are decoded as keys with a hyphen, '-', prepended to the name; the
+//     values are strings.
+//   - An element with neither attributes nor child elements is decoded as its text, a
+//     string.  Otherwise it is a map[string]interface{} value with a key for each attribute
+//     and child element; text is the value of the "#text" key.
+//   - An element with both child elements and text that isn't all white space has mixed
+//     content, which is decoded as a "#content" list of the text values and a map for each
+//     child element, in document order - <p>Hello <b>world</b>!</p> is decoded as
+//     {"p":{"#content":["Hello ",{"b":"world"},"!"]}}.  Otherwise the white space between
+//     child elements is dropped.
+//   - Child elements with the same tag are decoded as a list, in document order.  As
+//     MapToXml() encodes the keys of a map in sorted order, child elements that are not
+//     grouped by tag in sorted order are decoded as a "#content" list, like mixed content,
+//     so that their order is kept - <a><c/><b/></a> is decoded as {"a":{"#content":[{"c":""},
+//     {"b":""}]}}.
+//   - CDATA sections are decoded as text, so "#cdata" values are decoded as "#text" values.
+//   - Names keep their namespace prefixes, "soap:Envelope", and namespace declarations are
+//     decoded as attributes, "-xmlns:soap", so the namespaces are encoded again.
+//   - Comments, processing instructions and the XML declaration are skipped.
+//   - Documents in the ISO-8859-1, Windows-1252 and US-ASCII encodings are transcoded.
+func XmlToMap(xmlVal []byte) (map[string]interface{}, error) {
+	m, _, err := XmlReaderToMap(bytes.NewReader(xmlVal))
+	return m, err
+}
+
+// XmlToJson decodes an XML doc as a JSON object, the JSON encoding of XmlToMap().
+func XmlToJson(xmlVal []byte) ([]byte, error) {
+	m, err := XmlToMap(xmlVal)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(m); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
+
+// XmlReaderToMap implements XmlToMap() with an io.Reader.
+// Repeated calls will bulk process the stream of XML docs; no more than a doc is read from
+// rdr, which is read one byte per Read call if it isn't an io.ByteScanner - wrap an
+// *os.File or a net.Conn in a *bufio.Reader and pass that reader on each call.
+// The function returns: map[string]interface{}, pointer to source XML value, error.
+func XmlReaderToMap(rdr io.Reader) (map[string]interface{}, *[]byte, error) {
+	src := &recordingReader{r: byteScanner(rdr)}
+	m, err := xmlToMap(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, &src.b, nil
+}
+
+// recordingReader keeps the bytes that are read.
+type recordingReader struct {
+	r io.ByteScanner
+	b []byte
+}
+
+func (rr *recordingReader) ReadByte() (byte, error) {
+	c, err := rr.r.ReadByte()
+	if err == nil {
+		rr.b = append(rr.b, c)
+	}
+	return c, err
+}
+
+func (rr *recordingReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	c, err := rr.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	p[0] = c
+	return 1, nil
+}
+
+// xmlElem is an element that is being decoded.
+type xmlElem struct {
+	tag      string
+	m        map[string]interface{}
+	text     strings.Builder
+	children bool
+	content  []interface{} // text values and child elements, in document order
+}
+
+// addText adds character data to the element.
+func (el *xmlElem) addText(s []byte) {
+	el.text.Write(s)
+	if n := len(el.content); n > 0 {
+		if prev, ok := el.content[n-1].(string); ok {
+			el.content[n-1] = prev + string(s)
+			return
+		}
+	}
+	el.content = append(el.content, string(s))
+}
+
+// addChild adds the value of a child element tagged tag.
+func (el *xmlElem) addChild(tag string, v interface{}) {
+	addChild(el.m, tag, v)
+	el.content = append(el.content, map[string]interface{}{tag: v})
+}
+
+// value returns the decoded value of the element.
+func (el *xmlElem) value() interface{} {
+	text := el.text.String()
+	if len(el.m) == 0 {
+		return text
+	}
+	mixed := strings.TrimSpace(text) != ""
+	if !el.children {
+		if mixed {
+			el.m[DefaultTextKey] = text
+		}
+		return el.m
+	}
+	if !mixed && el.sorted() {
+		return el.m
+	}
+
+	// the attributes and the content; white space between child elements is dropped
+	// unless the content is mixed
+	content := el.content
+	if !mixed {
+		content = content[:0]
+		for _, v := range el.content {
+			if _, ok := v.(string); !ok {
+				content = append(content, v)
+			}
+		}
+	}
+	m := map[string]interface{}{DefaultContentKey: content}
+	for k, v := range el.m {
+		if strings.HasPrefix(k, DefaultAttrPrefix) {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// sorted reports whether the child elements are grouped by tag in sorted order, the order
+// that MapToXml() encodes them in.
+func (el *xmlElem) sorted() bool {
+	var prev string
+	for _, v := range el.content {
+		if child, ok := v.(map[string]interface{}); ok {
+			for tag := range child {
+				if tag < prev {
+					return false
+				}
+				prev = tag
+			}
+		}
+	}
+	return true
+}
+
+// addChild adds the value of a child element tagged tag to m; child elements with the same
+// tag are a list.
+func addChild(m map[string]interface{}, tag string, v interface{}) {
+	old, ok := m[tag]
+	if !ok {
+		m[tag] = v
+		return
+	}
+	if list, ok := old.([]interface{}); ok {
+		m[tag] = append(list, v)
+		return
+	}
+	m[tag] = []interface{}{old, v}
+}
+
+func xmlName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
+
+func xmlToMap(r io.Reader) (map[string]interface{}, error) {
+	d := xml.NewDecoder(r)
+	d.CharsetReader = charsetReader
+
+	var stack []*xmlElem
+	for {
+		t, err := d.RawToken()
+		if err != nil {
+			if err == io.EOF && len(stack) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			el := &xmlElem{tag: xmlName(t.Name), m: make(map[string]interface{}, len(t.Attr))}
+			for _, a := range t.Attr {
+				k := DefaultAttrPrefix + xmlName(a.Name)
+				if _, ok := el.m[k]; ok {
+					return nil, errors.New("attribute " + xmlName(a.Name) + " redefined in <" + el.tag + ">")
+				}
+				el.m[k] = a.Value
+			}
+			if len(stack) > 0 {
+				stack[len(stack)-1].children = true
+			}
+			stack = append(stack, el)
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].addText(t)
+			}
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, errors.New("unexpected end element: " + xmlName(t.Name))
+			}
+			el := stack[len(stack)-1]
+			if xmlName(t.Name) != el.tag {
+				return nil, errors.New("element <" + el.tag + "> closed by </" + xmlName(t.Name) + ">")
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return map[string]interface{}{el.tag: el.value()}, nil
+			}
+			stack[len(stack)-1].addChild(el.tag, el.value())
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package j2x
+
+import (
+	"io"
+	"strconv"
+	"testing"
+)
+
+// benchDoc returns a document with n records.
+func benchDoc(n int) map[string]interface{} {
+	records := make([]interface{}, n)
+	for i := range records {
+		records[i] = map[string]interface{}{
+			"-id":    i,
+			"name":   "record " + strconv.Itoa(i),
+			"note":   "a < b & c",
+			"amount": float64(i) * 1.5,
+			"active": i%2 == 0,
+			"tags":   []interface{}{"x", "y", "z"},
+			"nested": map[string]interface{}{"-kind": "n", "#text": "value"},
+		}
+	}
+	return map[string]interface{}{"export": map[string]interface{}{"record": records}}
+}
+
+func benchmarkMapToXml(b *testing.B, n int) {
+	m := benchDoc(n)
+	x, _ := MapToXml(m)
+	b.SetBytes(int64(len(x)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := MapToXml(m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMapToXml100(b *testing.B)  { benchmarkMapToXml(b, 100) }
+func BenchmarkMapToXml1000(b *testing.B) { benchmarkMapToXml(b, 1000) }
+
+func BenchmarkMapToXmlIndent(b *testing.B) {
+	m := benchDoc(1000)
+	x, _ := MapToXmlIndent(m, "", "  ")
+	b.SetBytes(int64(len(x)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := MapToXmlIndent(m, "", "  "); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncoderWriter(b *testing.B) {
+	m := benchDoc(1000)
+	x, _ := MapToXml(m)
+	b.SetBytes(int64(len(x)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := NewEncoder(io.Discard).Encode(m); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package j2x
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCData(t *testing.T) {
+	var tests = []struct {
+		json string
+		opts []Option
+		xml  string
+	}{
+		{`{"a":{"#cdata":"x < y"}}`, nil, `<a><![CDATA[x < y]]></a>`},
+		{`{"a":{"-id":"1","#cdata":"<b>bold</b>"}}`, nil, `<a id="1"><![CDATA[<b>bold</b>]]></a>`},
+		{`{"a":{"#cdata":"end ]]> here"}}`, nil, `<a><![CDATA[end ]]]]><![CDATA[> here]]></a>`},
+		{`{"a":{"#cdata":"]]>]]>"}}`, nil, `<a><![CDATA[]]]]><![CDATA[>]]]]><![CDATA[>]]></a>`},
+		{`{"a":{"#cdata":"cr\r\nlf"}}`, nil, `<a><![CDATA[cr]]>&#xD;<![CDATA[` + "\n" + `lf]]></a>`},
+		{`{"a":{"#cdata":"bad \u0001"}}`, nil, "<a><![CDATA[bad �]]></a>"},
+		{`{"a":{"#cdata":""}}`, nil, `<a></a>`},
+		{`{"a":{"#text":"x < y"}}`, nil, `<a>x &lt; y</a>`},
+		{`{"a":{"b":"x & y","c":"plain","d":1}}`, []Option{CData(CDataAlways)}, `<a><b><![CDATA[x & y]]></b><c><![CDATA[plain]]></c><d><![CDATA[1]]></d></a>`},
+		{`{"a":{"-x":"<","b":"x & y","c":"plain"}}`, []Option{CData(CDataWhenNeeded)}, `<a x="&lt;"><b><![CDATA[x & y]]></b><c>plain</c></a>`},
+		{`{"a":{"#x":"1","#cdata":"<"}}`, []Option{AttrPrefix("#")}, `<a x="1"><![CDATA[<]]></a>`},
+		{`{"a":{"$":"x & y"}}`, []Option{CDataKey("$")}, `<a><![CDATA[x & y]]></a>`},
+		{`{"a":"€ x é"}`, []Option{Declaration("", "ISO-8859-1", ""), CData(CDataAlways)},
+			"<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<a><![CDATA[]]>&#x20AC;<![CDATA[ x \xe9]]></a>"},
+		{`{"a":{"#cdata":"bad \u0001"}}`, []Option{Declaration("", "US-ASCII", "")},
+			"<?xml version=\"1.0\" encoding=\"US-ASCII\"?>\n<a><![CDATA[bad ]]>&#xFFFD;<![CDATA[]]></a>"},
+	}
+
+	fmt.Println("\nTestCData ...")
+	for _, tt := range tests {
+		for _, transcode := range []bool{false, true} {
+			var b bytes.Buffer
+			e := NewEncoder(&b, tt.opts...)
+			var err error
+			if transcode {
+				err = e.Transcode(strings.NewReader(tt.json))
+			} else {
+				err = e.EncodeJson([]byte(tt.json))
+			}
+			if err != nil {
+				t.Error(tt.json, err)
+				continue
+			}
+			if !transcode {
+				fmt.Println(b.String())
+			}
+			if b.String() != tt.xml {
+				t.Errorf("%s (transcode: %t): got %s want %s", tt.json, transcode, b.String(), tt.xml)
+			}
+		}
+	}
+
+	// the CDATA sections decode as the text
+	m, err := XmlToMap([]byte(`<a><![CDATA[end ]]]]><![CDATA[> here]]>&#xD;<![CDATA[<b>]]></a>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["a"] != "end ]]> here\r<b>" {
+		t.Errorf("got %q", m["a"])
+	}
+	var b bytes.Buffer
+	if err = NewEncoder(&b, Declaration("", "ISO-8859-1", ""), CData(CDataAlways)).Encode(map[string]interface{}{"a": "€ x é"}); err != nil {
+		t.Fatal(err)
+	}
+	if m, err = XmlToMap(b.Bytes()); err != nil || m["a"] != "€ x é" {
+		t.Errorf("got %q %v", m["a"], err)
+	}
+
+	_, err = JsonToXml([]byte(`{"a":{"#text":"t","#cdata":"c"}}`))
+	if e, ok := err.(*EncodeError); !ok || e.Kind != ErrTextKey {
+		t.Error("no ErrTextKey error:", err)
+	}
+	err = NewEncoder(&bytes.Buffer{}).Transcode(strings.NewReader(`{"a":{"#text":"t","#cdata":"c"}}`))
+	if e, ok := err.(*EncodeError); !ok || e.Kind != ErrTextKey {
+		t.Error("no ErrTextKey error from Transcode:", err)
+	}
+}
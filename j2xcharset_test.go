@@ -0,0 +1,91 @@
+package j2x
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestDeclaration(t *testing.T) {
+	m := map[string]interface{}{"doc": map[string]interface{}{"-cur": "€", "text": "café – ü 日本"}}
+
+	var tests = []struct {
+		opts []Option
+		want string
+	}{
+		{[]Option{Declaration("", "", "")},
+			"<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<doc cur=\"€\"><text>café – ü 日本</text></doc>"},
+		{[]Option{Declaration("1.1", "utf-8", "yes")},
+			"<?xml version=\"1.1\" encoding=\"utf-8\" standalone=\"yes\"?>\n<doc cur=\"€\"><text>café – ü 日本</text></doc>"},
+		{[]Option{Declaration("", "ISO-8859-1", "")},
+			"<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<doc cur=\"&#x20AC;\"><text>caf\xe9 &#x2013; \xfc &#x65E5;&#x672C;</text></doc>"},
+		{[]Option{Declaration("", "Windows-1252", "no")},
+			"<?xml version=\"1.0\" encoding=\"Windows-1252\" standalone=\"no\"?>\n<doc cur=\"\x80\"><text>caf\xe9 \x96 \xfc &#x65E5;&#x672C;</text></doc>"},
+		{[]Option{Declaration("", "US-ASCII", ""), Indent("", " ")},
+			"<?xml version=\"1.0\" encoding=\"US-ASCII\"?>\n<doc cur=\"&#x20AC;\">\n <text>caf&#xE9; &#x2013; &#xFC; &#x65E5;&#x672C;</text>\n</doc>\n"},
+	}
+
+	fmt.Println("\nTestDeclaration ...")
+	for i, tt := range tests {
+		var b bytes.Buffer
+		if err := NewEncoder(&b, tt.opts...).Encode(m); err != nil {
+			t.Error(i, "err:", err.Error())
+		}
+		fmt.Printf("v: %q\n", b.String())
+		if b.String() != tt.want {
+			t.Errorf("%d: got %q want %q", i, b.String(), tt.want)
+		}
+	}
+
+	for _, opt := range []Option{Declaration("", "EBCDIC", ""), Declaration("", "", "maybe"), Declaration("1.0\"?><x", "", ""), Declaration("2.0", "", "")} {
+		var b bytes.Buffer
+		if err := NewEncoder(&b, opt).Encode(m); err == nil {
+			t.Error("no error for invalid declaration")
+		}
+	}
+}
+
+func TestDeclarationNames(t *testing.T) {
+	latin1 := Declaration("", "ISO-8859-1", "")
+
+	fmt.Println("\nTestDeclarationNames ...")
+	for _, j := range []string{`{"é€":"x"}`, `{"a":{"-b€":"x"}}`, `{"a":{"{urn:x}c€":"x"}}`} {
+		for _, transcode := range []bool{false, true} {
+			var b bytes.Buffer
+			e := NewEncoder(&b, latin1)
+			var err error
+			if transcode {
+				err = e.Transcode(bytes.NewReader([]byte(j)))
+			} else {
+				err = e.EncodeJson([]byte(j))
+			}
+			if ee, ok := err.(*EncodeError); !ok || ee.Kind != ErrInvalidName {
+				t.Errorf("%s (transcode: %t): got %v %q want ErrInvalidName", j, transcode, err, b.String())
+			} else if !transcode {
+				fmt.Println(err)
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	if err := NewEncoder(&b, latin1).EncodeJson([]byte(`{"café":{"-é":"€"}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if want := "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<caf\xe9 \xe9=\"&#x20AC;\"/>"; b.String() != want {
+		t.Errorf("got %q want %q", b.String(), want)
+	}
+}
+
+func TestCharsetWriterSplit(t *testing.T) {
+	var b bytes.Buffer
+	cw := &charsetWriter{w: &b, enc: latin1}
+	src := []byte("é€")
+	for i := range src {
+		if _, err := cw.Write(src[i : i+1]); err != nil {
+			t.Fatal("err:", err.Error())
+		}
+	}
+	if want := "\xe9&#x20AC;"; b.String() != want {
+		t.Errorf("got %q want %q", b.String(), want)
+	}
+}
@@ -0,0 +1,109 @@
+package j2x
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestContent(t *testing.T) {
+	var tests = []struct {
+		json string
+		opts []Option
+		xml  string
+	}{
+		{`{"p":{"#content":["Hello ",{"b":"world"},"!"]}}`, nil, `<p>Hello <b>world</b>!</p>`},
+		{`{"p":{"-class":"x","#content":[{"i":"a"}," & ",{"b":{"-id":"1","#text":"b"}},null,2]}}`, nil, `<p class="x"><i>a</i> &amp; <b id="1">b</b>2</p>`},
+		{`{"p":{"#content":["x",{"#comment":"c","#cdata":"<y>","?pi":"z"},{"br":null},{"a":{"#content":["nested ",{"em":"e"}]}}]}}`, nil,
+			`<p>x<!--c--><![CDATA[<y>]]><?pi z?><br/><a>nested <em>e</em></a></p>`},
+		{`{"p":{"#content":"just text"}}`, nil, `<p>just text</p>`},
+		{`{"p":{"#content":[]}}`, nil, `<p></p>`},
+		{`{"doc":{"title":"T","p":{"#content":["Hello ",{"b":{"i":"world"}},"!"]},"q":"x"}}`, []Option{Indent("", "  ")},
+			"<doc>\n  <title>T</title>\n  <p>Hello <b><i>world</i></b>!</p>\n  <q>x</q>\n</doc>\n"},
+		{`{"p":{"$":["a",{"b":"c"}]}}`, []Option{ContentKey("$")}, `<p>a<b>c</b></p>`},
+	}
+
+	fmt.Println("\nTestContent ...")
+	for _, tt := range tests {
+		for _, transcode := range []bool{false, true} {
+			var b bytes.Buffer
+			e := NewEncoder(&b, append([]Option{Ordering(JsonKeyOrder)}, tt.opts...)...)
+			var err error
+			if transcode {
+				err = e.Transcode(strings.NewReader(tt.json))
+			} else {
+				err = e.EncodeJson([]byte(tt.json))
+			}
+			if err != nil {
+				t.Error(tt.json, err)
+				continue
+			}
+			if !transcode {
+				fmt.Print(b.String(), "\n")
+			}
+			if b.String() != tt.xml {
+				t.Errorf("%s (transcode: %t): got %s want %s", tt.json, transcode, b.String(), tt.xml)
+			}
+		}
+	}
+
+	var errs = []struct {
+		json string
+		path string
+	}{
+		{`{"p":{"#content":["a"],"b":"c"}}`, "/p/#content"},
+		{`{"p":{"#content":["a"],"#text":"c"}}`, "/p/#content"},
+		{`{"p":{"#content":["a",["b"]]}}`, "/p/#content[1]"},
+		{`{"p":{"#content":[{"-id":"1"}]}}`, "/p/#content[0]/-id"},
+		{`{"p":{"#content":[{"#text":{"b":1}}]}}`, "/p/#content[0]/#text"},
+	}
+	for _, tt := range errs {
+		for _, transcode := range []bool{false, true} {
+			e := NewEncoder(&bytes.Buffer{}, Ordering(JsonKeyOrder))
+			var err error
+			if transcode {
+				err = e.Transcode(strings.NewReader(tt.json))
+			} else {
+				err = e.EncodeJson([]byte(tt.json))
+			}
+			ee, ok := err.(*EncodeError)
+			if !ok || ee.Kind != ErrTextKey || ee.Path != tt.path {
+				t.Errorf("%s (transcode: %t): got %v want ErrTextKey at %s", tt.json, transcode, err, tt.path)
+			}
+		}
+	}
+}
+
+func TestXmlToMapContent(t *testing.T) {
+	var tests = []struct {
+		xml  string
+		json string
+	}{
+		{`<p>Hello <b>world</b>!</p>`, `{"p":{"#content":["Hello ",{"b":"world"},"!"]}}`},
+		{`<p id="1"><i>a</i> <![CDATA[&]]> <b x="y">b</b><b/></p>`, `{"p":{"#content":[{"i":"a"}," & ",{"b":{"#text":"b","-x":"y"}},{"b":""}],"-id":"1"}}`},
+		{"<p>\n  <b>x</b>\n  <b>y</b>\n</p>", `{"p":{"b":["x","y"]}}`},
+	}
+
+	fmt.Println("\nTestXmlToMapContent ...")
+	for _, tt := range tests {
+		j, err := XmlToJson([]byte(tt.xml))
+		if err != nil {
+			t.Error(tt.xml, err)
+			continue
+		}
+		fmt.Println(string(j))
+		if string(j) != tt.json {
+			t.Errorf("%s: got %s want %s", tt.xml, j, tt.json)
+		}
+		m, _ := XmlToMap([]byte(tt.xml))
+		x, err := MapToXmlIndent(m, "", "  ")
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if j2, _ := XmlToJson(x); string(j2) != tt.json {
+			t.Errorf("%s: round trip %s", tt.xml, j2)
+		}
+	}
+}
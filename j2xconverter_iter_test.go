@@ -0,0 +1,35 @@
+//go:build go1.23
+
+package j2x
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestConverterAll(t *testing.T) {
+	data := `{ "a":1 }, { "b":{ "-c":{} } }, { "d":2 }, { "e":`
+
+	fmt.Println("\nTestConverterAll ...")
+	var got []string
+	for rec, err := range NewConverter(strings.NewReader(data)).All() {
+		fmt.Println(rec.Index, rec.Offset, string(rec.XML), err)
+		if err != nil {
+			got = append(got, fmt.Sprint(rec.Index, " error"))
+			continue
+		}
+		got = append(got, fmt.Sprint(rec.Index, " ", string(rec.XML)))
+	}
+	want := "[0 <a>1</a> 1 error 2 <d>2</d> 3 error]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("got %s want %s", got, want)
+	}
+
+	var n int
+	for range NewConverter(strings.NewReader(data)).All() {
+		if n++; n == 1 {
+			break
+		}
+	}
+}
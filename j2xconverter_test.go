@@ -0,0 +1,60 @@
+package j2x
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestConverter(t *testing.T) {
+	data := `{ "a":1 }
+{ "b":{ "-c":[ 2 ] } }
+[ 1, 2 ]
+"text"
+{ "d":true }`
+	want := []string{`<a>1</a>`, ``, `<doc><item>1</item><item>2</item></doc>`, `<doc>text</doc>`, `<d>true</d>`}
+	offsets := []int64{0, 10, 33, 42, 49}
+
+	fmt.Println("\nTestConverter ...")
+	c := NewConverter(strings.NewReader(data))
+	var n int
+	for {
+		for c.Next() {
+			fmt.Println(c.Index(), c.Offset(), string(c.JSON()), string(c.XML()))
+			if string(c.XML()) != want[n] || c.Offset() != offsets[n] || c.Index() != n {
+				t.Errorf("record %d: %d %d %s", n, c.Index(), c.Offset(), c.XML())
+			}
+			n++
+		}
+		err := c.Err()
+		if err == nil {
+			break
+		}
+		fmt.Println("err:", err)
+		var re *RecordError
+		var ee *EncodeError
+		if !errors.As(err, &re) || re.Index != 1 || re.Offset != 10 || !errors.As(err, &ee) || ee.Path != "/b/-c" {
+			t.Fatal("record error:", err)
+		}
+		if string(c.JSON()) != `{ "b":{ "-c":[ 2 ] } }` {
+			t.Error("record error JSON:", string(c.JSON()))
+		}
+		n++
+	}
+	if n != len(want) {
+		t.Error("records:", n)
+	}
+
+	c = NewConverter(bytes.NewBufferString(`{ "a":1 } { "b": } { "c":3 }`))
+	for c.Next() {
+	}
+	var re *RecordError
+	if !errors.As(c.Err(), &re) || re.Index != 1 || re.Offset != 10 || !isSyntaxError(re.Err) {
+		t.Error("syntax error:", c.Err())
+	}
+	if c.Next() {
+		t.Error("Next after syntax error")
+	}
+}
@@ -0,0 +1,134 @@
+package j2x
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestEncoder(t *testing.T) {
+	m := map[string]interface{}{"tag1": nil, "tag2": "a > b", "tag3": map[string]interface{}{"-attr": `"q"`}}
+
+	var tests = []struct {
+		opts []Option
+		want string
+	}{
+		{nil, `<doc><tag1/><tag2>a &gt; b</tag2><tag3 attr="&quot;q&quot;"/></doc>`},
+		{[]Option{GoXmlEmptyElemSyntax(true)}, `<doc><tag1></tag1><tag2>a &gt; b</tag2><tag3 attr="&quot;q&quot;"></tag3></doc>`},
+		{[]Option{RootTag("root")}, `<root><tag1/><tag2>a &gt; b</tag2><tag3 attr="&quot;q&quot;"/></root>`},
+		{[]Option{Escaping(EscapeMinimal)}, `<doc><tag1/><tag2>a > b</tag2><tag3 attr="&quot;q&quot;"/></doc>`},
+		{[]Option{Escaping(EscapeNone)}, `<doc><tag1/><tag2>a > b</tag2><tag3 attr=""q""/></doc>`},
+		{[]Option{Indent("", " ")}, "<doc>\n <tag1/>\n <tag2>a &gt; b</tag2>\n <tag3 attr=\"&quot;q&quot;\"/>\n</doc>\n"},
+	}
+
+	fmt.Println("\nTestEncoder ...")
+	for i, tt := range tests {
+		var b bytes.Buffer
+		if err := NewEncoder(&b, tt.opts...).Encode(m); err != nil {
+			t.Error(i, "err:", err.Error())
+		}
+		fmt.Println("v:", b.String())
+		if b.String() != tt.want {
+			t.Errorf("%d: got %s want %s", i, b.String(), tt.want)
+		}
+	}
+}
+
+func TestEncoderJson(t *testing.T) {
+	s := `{ "b":1, "a":2 }`
+
+	fmt.Println("\nTestEncoderJson ...", s)
+	var b bytes.Buffer
+	if err := NewEncoder(&b, RootTag("r")).EncodeJson([]byte(s)); err != nil {
+		t.Fatal("err:", err.Error())
+	}
+	if want := `<r><a>2</a><b>1</b></r>`; b.String() != want {
+		t.Errorf("got %s want %s", b.String(), want)
+	}
+
+	b.Reset()
+	if err := NewEncoder(&b, RootTag("r"), Ordering(JsonKeyOrder)).EncodeJson([]byte(s)); err != nil {
+		t.Fatal("err:", err.Error())
+	}
+	if want := `<r><b>1</b><a>2</a></r>`; b.String() != want {
+		t.Errorf("got %s want %s", b.String(), want)
+	}
+}
+
+func TestEncoderConcurrent(t *testing.T) {
+	m := map[string]interface{}{"empty": nil}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(goXml bool) {
+			defer wg.Done()
+			want := `<empty/>`
+			if goXml {
+				want = `<empty></empty>`
+			}
+			for j := 0; j < 100; j++ {
+				var b bytes.Buffer
+				if err := NewEncoder(&b, GoXmlEmptyElemSyntax(goXml)).Encode(m); err != nil {
+					t.Error("err:", err.Error())
+					return
+				}
+				if b.String() != want {
+					t.Errorf("got %s want %s", b.String(), want)
+					return
+				}
+			}
+		}(i%2 == 0)
+	}
+	wg.Wait()
+}
+
+func TestAttrPrefixTextKey(t *testing.T) {
+	var tests = []struct {
+		json string
+		opts []Option
+		want string
+	}{
+		{`{ "a":{ "@id":1, "$":"text" } }`, []Option{AttrPrefix("@"), TextKey("$")}, `<a id="1">text</a>`},
+		{`{ "a":{ "@id":1, "b":{ "$t":"x" } } }`, []Option{AttrPrefix("@"), TextKey("$t")}, `<a id="1"><b>x</b></a>`},
+		{`{ "a":{ "_id":1, "_text":"y" } }`, []Option{AttrPrefix("_"), TextKey("_text")}, `<a id="1">y</a>`},
+		{`{ "a":{ "-id":1, "b":2 } }`, []Option{AttrPrefix("@"), Names(NameReplace)}, `<a><_-id>1</_-id><b>2</b></a>`},
+		{`{ "a":{ "id":1, "b":2 } }`, []Option{AttrPrefix("")}, `<a><b>2</b><id>1</id></a>`},
+	}
+
+	fmt.Println("\nTestAttrPrefixTextKey ...")
+	for i, tt := range tests {
+		var b bytes.Buffer
+		if err := NewEncoder(&b, tt.opts...).EncodeJson([]byte(tt.json)); err != nil {
+			t.Error(i, "err:", err.Error())
+		}
+		fmt.Println("v:", b.String())
+		if b.String() != tt.want {
+			t.Errorf("%d: got %s want %s", i, b.String(), tt.want)
+		}
+	}
+
+	var b bytes.Buffer
+	err := NewEncoder(&b, TextKey("$")).EncodeJson([]byte(`{ "a":{ "$":"x", "b":1 } }`))
+	if err == nil {
+		t.Error("no error for $ with other keys")
+	}
+
+	// an empty text key is not a text key, so the empty key is an invalid name
+	for _, j := range []string{`{ "a":{ "-x":"1", "":"x" } }`, `{ "a":{ "#content":[ { "":"x" } ] } }`} {
+		for _, transcode := range []bool{false, true} {
+			b.Reset()
+			e := NewEncoder(&b, TextKey(""))
+			if transcode {
+				err = e.Transcode(strings.NewReader(j))
+			} else {
+				err = e.EncodeJson([]byte(j))
+			}
+			if ee, ok := err.(*EncodeError); !ok || ee.Kind != ErrInvalidName {
+				t.Errorf("%s (transcode: %t): got %v %s want ErrInvalidName", j, transcode, err, b.String())
+			}
+		}
+	}
+}
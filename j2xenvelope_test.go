@@ -0,0 +1,85 @@
+package j2x
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEnvelopeWriter(t *testing.T) {
+	fmt.Println("\nTestEnvelopeWriter ...")
+	var b bytes.Buffer
+	ew := NewEnvelopeWriter(&b, "records", map[string]string{"version": "1 & 2", "xmlns:x": "urn:x"},
+		Declaration("", "", ""), Indent("", "  "))
+	if err := ew.Encode(map[string]interface{}{"x:rec": map[string]interface{}{"-id": 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ew.Encode(map[string]interface{}{"rec": map[string]interface{}{"-id": []interface{}{2}}}); err == nil {
+		t.Error("no error for list attribute")
+	}
+	if err := ew.EncodeJson([]byte(`{ "rec":{ "a":"b" } }`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ew.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println(b.String())
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<records version="1 &amp; 2" xmlns:x="urn:x">
+  <x:rec id="1"/>
+  <rec>
+    <a>b</a>
+  </rec>
+</records>
+`
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+	if err := ew.Encode(map[string]interface{}{"a": 1}); err == nil {
+		t.Error("no error after Close")
+	}
+
+	b.Reset()
+	if err := NewEnvelopeWriter(&b, "empty", nil).Close(); err != nil || b.String() != "<empty></empty>" {
+		t.Errorf("empty envelope: %q %v", b.String(), err)
+	}
+	if err := NewEnvelopeWriter(&b, "bad tag", nil).Close(); err == nil {
+		t.Error("no error for invalid tag")
+	}
+}
+
+func TestJsonReaderToEnvelope(t *testing.T) {
+	fmt.Println("\nTestJsonReaderToEnvelope ...")
+	data := `{ "rec":{ "-id":1 } }
+{ "rec":{ "-id":2 } }
+[ 3, 4 ]`
+	var b bytes.Buffer
+	if err := JsonReaderToEnvelope(strings.NewReader(data), &b, "records", nil); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println(b.String())
+	if b.String() != `<records><rec id="1"/><rec id="2"/><doc><item>3</item><item>4</item></doc></records>` {
+		t.Error("got:", b.String())
+	}
+	// the output is a well-formed document
+	dec := xml.NewDecoder(&b)
+	for {
+		if _, err := dec.Token(); err != nil {
+			if err != io.EOF {
+				t.Error(err)
+			}
+			break
+		}
+	}
+
+	b.Reset()
+	err := JsonReaderToEnvelope(strings.NewReader(`{ "a":1 } { "b":{ "-c":{} } } { "d":3 }`), &b, "records", nil)
+	var re *RecordError
+	if !errors.As(err, &re) || re.Index != 1 || re.Offset != 10 || b.String() != "<records><a>1</a></records>" {
+		t.Error("record error:", err, b.String())
+	}
+}
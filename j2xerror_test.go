@@ -0,0 +1,46 @@
+package j2x
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestEncodeError(t *testing.T) {
+	var tests = []struct {
+		json string
+		kind ErrorKind
+		path string
+	}{
+		{`{ "order":{ "items":[ { "-qty":1 }, { "-qty":2 }, { "-qty":3 }, { "-qty":[ 4 ] } ] } }`, ErrAttrValue, "/order/items[3]/-qty"},
+		{`{ "a":{ "b":{ "c":{ "-x":{ "y":1 } } } } }`, ErrAttrValue, "/a/b/c/-x"},
+		{`{ "a":{ "b":{ "#text":1, "c":2 } } }`, ErrTextKey, "/a/b/#text"},
+		{`{ "a":{ "b":[ [ 1, { "x:c":2 } ] ] } }`, ErrNamespace, "/a/b[0][1]/x:c"},
+		{`{ "a":1, "b":{ "-x:y":2 } }`, ErrNamespace, "/b/-x:y"},
+		{`{ "a":{ "{urn:x":2 } }`, ErrInvalidName, "/a/{urn:x"},
+		{`{ "doc":{ "":1 } }`, ErrInvalidName, "/doc/"},
+	}
+
+	fmt.Println("\nTestEncodeError ...")
+	for _, tt := range tests {
+		_, err := JsonToXml([]byte(tt.json))
+		if err == nil {
+			t.Error("no error for:", tt.json)
+			continue
+		}
+		fmt.Println("err:", err.Error())
+		var ee *EncodeError
+		if !errors.As(err, &ee) {
+			t.Errorf("%s: not an *EncodeError: %T", tt.json, err)
+			continue
+		}
+		if ee.Kind != tt.kind || ee.Path != tt.path {
+			t.Errorf("%s: got %v %s want %v %s", tt.json, ee.Kind, ee.Path, tt.kind, tt.path)
+		}
+	}
+
+	_, err := MapToXmlIndent(map[string]interface{}{"a": []interface{}{1, map[string]interface{}{"-b": nil}}}, "", " ")
+	if ee, ok := err.(*EncodeError); !ok || ee.Path != "/a[1]/-b" {
+		t.Errorf("MapToXmlIndent: got %v", err)
+	}
+}
@@ -0,0 +1,256 @@
+//go:build go1.18
+
+package j2x
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var fuzzSeeds = []string{
+	`{ "key":"value" }`,
+	`{ "one":1, "two":1.999, "3":"three", "four":false }`,
+	`{"doc":{"-id":"1","#text":"x & y","list":[1,"two",{"c":null}]}}`,
+	`[1,[2,3],{"a":[]},{}]`,
+	`{"soap:Envelope":{"-xmlns:soap":"urn:s","soap:Body":{"m":"\u0001<\r\n>"}}}`,
+	`{"a b":{"-":"","#text":["t"]}}`,
+	`"scalar"`,
+	`{"n":-1.5e300,"big":123456789012345678901234567890}`,
+	`{"a":"1"} {"b":"2"}, [3]`,
+	`{"!DOCTYPE":"a [<!ENTITY e \"x\">]","#comment":"c","a":{"?pi":"x","#cdata":"]]>"}}`,
+	`{"p":{"-id":"1","#content":["Hello ",{"b":{"#content":[{"i":"x"},"y"]}},null,"!"]}}`,
+}
+
+// wellFormed checks that x is a single XML element, optionally preceded and followed by
+// the declaration, comments, etc., that encoding/xml can parse.
+func wellFormed(x []byte) error {
+	d := xml.NewDecoder(bytes.NewReader(x))
+	d.CharsetReader = charsetReader
+	roots, depth := 0, 0
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				roots++
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			if depth == 0 && len(bytes.TrimSpace(t)) > 0 {
+				return errors.New("character data outside the root element")
+			}
+		}
+	}
+	if roots != 1 {
+		return errors.New("not one root element")
+	}
+	return nil
+}
+
+// roundTripValue returns the value that XmlToMap() decodes for the XML encoding of the
+// JSON value v, by the conventions of MapToXml(), or false if v uses encoding options
+// that don't round trip - attribute and text keys, names that aren't valid, namespaces,
+// and empty or nested lists.
+func roundTripValue(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case nil:
+		return "", true
+	case string:
+		return strings.Map(func(r rune) rune {
+			if !isXmlChar(r) {
+				return '\uFFFD'
+			}
+			return r
+		}, v), true
+	case json.Number:
+		return string(v), true
+	case bool:
+		if v {
+			return "true", true
+		}
+		return "false", true
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return "", true
+		}
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			if !isName(k) || strings.ContainsAny(k, ":-#") {
+				return nil, false
+			}
+			if list, ok := val.([]interface{}); ok {
+				if len(list) == 0 {
+					return nil, false
+				}
+				items := make([]interface{}, len(list))
+				for i, item := range list {
+					if _, ok := item.([]interface{}); ok {
+						return nil, false
+					}
+					if items[i], ok = roundTripValue(item); !ok {
+						return nil, false
+					}
+				}
+				if len(items) == 1 {
+					m[k] = items[0]
+				} else {
+					m[k] = items
+				}
+				continue
+			}
+			rv, ok := roundTripValue(val)
+			if !ok {
+				return nil, false
+			}
+			m[k] = rv
+		}
+		return m, true
+	}
+	return nil, false
+}
+
+func FuzzJsonToXml(f *testing.F) {
+	for _, s := range fuzzSeeds {
+		f.Add([]byte(s))
+	}
+	f.Fuzz(func(t *testing.T, j []byte) {
+		x, err := JsonToXml(j)
+		if err != nil {
+			return
+		}
+		if err := wellFormed(x); err != nil {
+			t.Fatalf("%s: %s: %v", j, x, err)
+		}
+
+		// JSON -> XML -> JSON
+		var v interface{}
+		if unmarshalJson(j, &v) != nil {
+			return
+		}
+		root := map[string]interface{}{DefaultRootTag: v}
+		if m, ok := v.(map[string]interface{}); ok && len(m) == 1 {
+			for _, val := range m {
+				if _, ok := val.([]interface{}); !ok {
+					root = m
+				}
+			}
+		} else if list, ok := v.([]interface{}); ok {
+			root = map[string]interface{}{DefaultRootTag: map[string]interface{}{DefaultItemTag: list}}
+		}
+		want, ok := roundTripValue(root)
+		if !ok {
+			return
+		}
+		got, err := XmlToMap(x)
+		if err != nil {
+			t.Fatalf("%s: %s: %v", j, x, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: %s:\ngot  %v\nwant %v", j, x, got, want)
+		}
+	})
+}
+
+func FuzzMapToXmlIndent(f *testing.F) {
+	for _, s := range fuzzSeeds {
+		f.Add([]byte(s), "", "  ")
+	}
+	f.Add([]byte(`{"a":{"b":["x","y"]}}`), "\t", "\t")
+	f.Fuzz(func(t *testing.T, j []byte, prefix, indent string) {
+		var m map[string]interface{}
+		if unmarshalJson(j, &m) != nil {
+			return
+		}
+		x, err := MapToXmlIndent(m, prefix, indent)
+		if err != nil {
+			return
+		}
+		if strings.Trim(prefix+indent, " \t\r\n") != "" {
+			// only white space keeps the document well-formed
+			return
+		}
+		if err := wellFormed(x); err != nil {
+			t.Fatalf("%s %q %q: %s: %v", j, prefix, indent, x, err)
+		}
+
+		// the indented document decodes as the compact one does
+		c, err := MapToXml(m)
+		if err != nil {
+			t.Fatalf("%s: indented but not compact: %v", j, err)
+		}
+		mi, err := XmlToMap(x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mc, err := XmlToMap(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(trimText(mi), trimText(mc)) {
+			t.Fatalf("%s:\n%s\n%s", j, x, c)
+		}
+	})
+}
+
+// trimText trims the white space of the strings in v; indentation only adds white space
+// around the text of elements with child elements.
+func trimText(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, val := range v {
+			l[i] = trimText(val)
+		}
+		return l
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[k] = trimText(val)
+		}
+		return m
+	}
+	return v
+}
+
+func FuzzGetJson(f *testing.F) {
+	for _, s := range fuzzSeeds {
+		f.Add([]byte(s))
+	}
+	f.Add([]byte("\xef\xbb\xbf{\"a\":1}\n\n[2]x"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := bytes.NewReader(data)
+		for n := 0; ; n++ {
+			if n > len(data) {
+				t.Fatalf("%q: more values than bytes", data)
+			}
+			jb, err := getJson(r)
+			if err != nil {
+				return
+			}
+			if !json.Valid(*jb) {
+				t.Fatalf("%q: invalid value %q", data, *jb)
+			}
+			if x, err := JsonToXml(*jb); err == nil {
+				if err := wellFormed(x); err != nil {
+					t.Fatalf("%s: %s: %v", *jb, x, err)
+				}
+			}
+		}
+	})
+}
@@ -3,6 +3,7 @@ package j2x
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -32,3 +33,26 @@ func TestMashalIndent(t *testing.T) {
 	}
 	fmt.Printf("v:\n%s",string(v))
 }
+
+func TestEscapeIndent(t *testing.T) {
+	m := map[string]interface{}{
+		"doc": map[string]interface{}{
+			"-attr": "a\"b",
+			"str":   "x < y & z",
+			"bytes": []byte("<>"),
+			"text":  map[string]interface{}{"-n": 1, "#text": "'q'"},
+		},
+	}
+
+	fmt.Println("\nTestEscapeIndent ...")
+	v, err := MapToXmlIndent(m, "", "  ")
+	if err != nil {
+		t.Error("err:", err.Error())
+	}
+	fmt.Printf("v:\n%s", string(v))
+	for _, want := range []string{`attr="a&quot;b"`, `<str>x &lt; y &amp; z</str>`, `<bytes>&lt;&gt;</bytes>`, `<text n="1">&apos;q&apos;</text>`} {
+		if !strings.Contains(string(v), want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package j2x
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestListInList(t *testing.T) {
+	s := `{ "a":{ "b":[ [ 1, 2 ], 3, [ [ 4 ] ] ] } }`
+
+	fmt.Println("\nTestListInList ...", s)
+	v, err := JsonToXml([]byte(s))
+	if err != nil {
+		t.Fatal("err:", err.Error())
+	}
+	fmt.Println("v:", string(v))
+	want := `<a><b><item>1</item><item>2</item></b><b>3</b><b><item><item>4</item></item></b></a>`
+	if string(v) != want {
+		t.Errorf("got %s want %s", v, want)
+	}
+}
+
+func TestWrapArrays(t *testing.T) {
+	var tests = []struct {
+		json  string
+		namer ItemNamer
+		want  string
+	}{
+		{`{ "items":[ 1, 2 ] }`, FixedItemName("item"), `<items><item>1</item><item>2</item></items>`},
+		{`{ "entries":[ 1 ], "boxes":[ { "-id":1 } ], "data":[ true ] }`, SingularItemName,
+			`<doc><boxes><box id="1"/></boxes><data><item>true</item></data><entries><entry>1</entry></entries></doc>`},
+		{`{ "rows":[ [ 1, 2 ], [] ] }`, SingularItemName, `<rows><row><item>1</item><item>2</item></row><row/></rows>`},
+		{`{ "a":{ "list":[] } }`, FixedItemName("i"), `<a><list/></a>`},
+		{`{ "a":[ "x" ] }`, func(key string) string { return strings.ToUpper(key) + "-item" }, `<a><A-item>x</A-item></a>`},
+	}
+
+	fmt.Println("\nTestWrapArrays ...")
+	for i, tt := range tests {
+		var b bytes.Buffer
+		if err := NewEncoder(&b, WrapArrays(tt.namer)).EncodeJson([]byte(tt.json)); err != nil {
+			t.Error(i, "err:", err.Error())
+		}
+		fmt.Println("v:", b.String())
+		if b.String() != tt.want {
+			t.Errorf("%d: got %s want %s", i, b.String(), tt.want)
+		}
+	}
+
+	var b bytes.Buffer
+	err := NewEncoder(&b, WrapArrays(SingularItemName), Indent("", " ")).EncodeJson([]byte(`{ "lines":[ "a", [ "b" ] ] }`))
+	if err != nil {
+		t.Fatal("err:", err.Error())
+	}
+	fmt.Printf("v:\n%s", b.String())
+	if want := "<lines>\n <line>a</line>\n <line>\n  <item>b</item>\n </line>\n</lines>\n"; b.String() != want {
+		t.Errorf("indent: got %q want %q", b.String(), want)
+	}
+}
+
+func TestSingularItemName(t *testing.T) {
+	for key, want := range map[string]string{
+		"items": "item", "entries": "entry", "boxes": "box", "classes": "class",
+		"matches": "match", "dishes": "dish", "buzzes": "buzz", "class": "item", "data": "item", "s": "item",
+		"status": "item", "bus": "item", "news": "item", "series": "item", "analysis": "item", "views": "view", "ideas": "idea",
+	} {
+		if got := SingularItemName(key); got != want {
+			t.Errorf("%s: got %s want %s", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package j2x
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMarkup(t *testing.T) {
+	var tests = []struct {
+		json string
+		opts []Option
+		xml  string
+	}{
+		{`{"!DOCTYPE":"note SYSTEM \"note.dtd\"","#comment":" generated ","?xml-stylesheet":"type=\"text/xsl\" href=\"s.xsl\"","note":{"to":"Tove"}}`, nil,
+			`<!DOCTYPE note SYSTEM "note.dtd"><!-- generated --><?xml-stylesheet type="text/xsl" href="s.xsl"?><note><to>Tove</to></note>`},
+		{`{"a":{"-id":"1","#comment":["one","two"],"b":"x","?pi":""}}`, nil,
+			`<a id="1"><!--one--><!--two--><b>x</b><?pi?></a>`},
+		{`{"#comment":"before","a":{"b":1},"?after":"x"}`, nil, `<!--before--><a><b>1</b></a><?after x?>`},
+		{`{"#comment":"c","a":1,"b":2,"?p":"x"}`, nil, `<!--c--><doc><a>1</a><b>2</b></doc><?p x?>`},
+		{`{"#comment":"c","a":[1,2]}`, nil, `<!--c--><doc><a>1</a><a>2</a></doc>`},
+		{`{"#comment":"c"}`, nil, `<!--c--><doc/>`},
+		{`{"#comment":"c","a":1,"?p":"x"}`, []Option{RootTag("r")}, `<!--c--><r><a>1</a></r><?p x?>`},
+		{`{"!DOCTYPE":"html","html":{"#comment":"c","body":{"p":"x"}}}`, []Option{Indent("", "  ")},
+			"<!DOCTYPE html>\n<html>\n  <!--c-->\n  <body>\n    <p>x</p>\n  </body>\n</html>\n"},
+		{`{"!DOCTYPE":"doc [<!ENTITY e \"x\">]","doc":"y"}`, nil, `<!DOCTYPE doc [<!ENTITY e "x">]><doc>y</doc>`},
+		{`{"!DOCTYPE":"doc","a":1,"b":2}`, nil, `<!DOCTYPE doc><doc><a>1</a><b>2</b></doc>`},
+		{`{"!DOCTYPE":"r","a":1}`, []Option{RootTag("r")}, `<!DOCTYPE r><r><a>1</a></r>`},
+	}
+
+	fmt.Println("\nTestMarkup ...")
+	for _, tt := range tests {
+		for _, transcode := range []bool{false, true} {
+			var b bytes.Buffer
+			e := NewEncoder(&b, append([]Option{Ordering(JsonKeyOrder)}, tt.opts...)...)
+			var err error
+			if transcode {
+				err = e.Transcode(strings.NewReader(tt.json))
+			} else {
+				err = e.EncodeJson([]byte(tt.json))
+			}
+			if err != nil {
+				t.Error(tt.json, err)
+				continue
+			}
+			if !transcode {
+				fmt.Print(b.String(), "\n")
+			}
+			if b.String() != tt.xml {
+				t.Errorf("%s (transcode: %t): got %s want %s", tt.json, transcode, b.String(), tt.xml)
+			}
+		}
+	}
+
+	// in sorted key order the markup keys come first
+	m := map[string]interface{}{"note": "x", "#comment": "c", "!DOCTYPE": "note", "?pi": "y"}
+	x, err := MapToXml(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(x) != `<!DOCTYPE note><!--c--><?pi y?><note>x</note>` {
+		t.Error("got", string(x))
+	}
+}
+
+func TestMarkupErrors(t *testing.T) {
+	var tests = []struct {
+		json string
+		path string
+	}{
+		{`{"a":{"#comment":"x -- y"}}`, "/a/#comment"},
+		{`{"a":{"#comment":"ends with -"}}`, "/a/#comment"},
+		{`{"a":{"#comment":["ok","bad--"]}}`, "/a/#comment[1]"},
+		{`{"a":{"#comment":{"b":1}}}`, "/a/#comment"},
+		{`{"a":{"#comment":"\u0001"}}`, "/a/#comment"},
+		{`{"a":{"?xml":"version=\"1.0\""}}`, "/a/?xml"},
+		{`{"a":{"?XmL":"x"}}`, "/a/?XmL"},
+		{`{"a":{"?1pi":"x"}}`, "/a/?1pi"},
+		{`{"a":{"?p:i":"x"}}`, "/a/?p:i"},
+		{`{"a":{"?pi":"x ?> y"}}`, "/a/?pi"},
+		{`{"a":{"!DOCTYPE":"a"}}`, "/a/!DOCTYPE"},
+		{`{"a":"x","!DOCTYPE":"a"}`, "/!DOCTYPE"},
+		{`{"!DOCTYPE":"a><b","a":"x"}`, "/!DOCTYPE"},
+		{`{"!DOCTYPE":"","a":"x"}`, "/!DOCTYPE"},
+		{`{"!DOCTYPE":"a [<!ENTITY e \"x\">","a":"x"}`, "/!DOCTYPE"},
+		{`{"!DOCTYPE":"a","b":1,"c":2}`, "/!DOCTYPE"},
+		{`{"!DOCTYPE":"a","b":1}`, "/!DOCTYPE"},
+		{`{"!DOCTYPE":"a","a":[1,2]}`, "/!DOCTYPE"},
+		{`{"!DOCTYPE":["a","a"],"a":1}`, "/!DOCTYPE"},
+	}
+
+	fmt.Println("\nTestMarkupErrors ...")
+	for _, tt := range tests {
+		for _, transcode := range []bool{false, true} {
+			e := NewEncoder(&bytes.Buffer{}, Ordering(JsonKeyOrder))
+			var err error
+			if transcode {
+				err = e.Transcode(strings.NewReader(tt.json))
+			} else {
+				err = e.EncodeJson([]byte(tt.json))
+			}
+			ee, ok := err.(*EncodeError)
+			if !ok || ee.Kind != ErrMarkup || ee.Path != tt.path {
+				t.Errorf("%s (transcode: %t): got %v want ErrMarkup at %s", tt.json, transcode, err, tt.path)
+				continue
+			}
+			if !transcode {
+				fmt.Println(err)
+			}
+		}
+	}
+
+	// markup can't have character references
+	latin1 := Declaration("", "ISO-8859-1", "")
+	for _, j := range []string{`{"#comment":"€"}`, `{"a":{"?pi":"x €"}}`, `{"!DOCTYPE":"a SYSTEM \"€.dtd\"","a":"x"}`} {
+		for _, transcode := range []bool{false, true} {
+			e := NewEncoder(&bytes.Buffer{}, latin1)
+			var err error
+			if transcode {
+				err = e.Transcode(strings.NewReader(j))
+			} else {
+				err = e.EncodeJson([]byte(j))
+			}
+			if ee, ok := err.(*EncodeError); !ok || ee.Kind != ErrMarkup {
+				t.Errorf("%s (transcode: %t): got %v want ErrMarkup", j, transcode, err)
+			}
+		}
+	}
+	var b bytes.Buffer
+	if err := NewEncoder(&b, latin1).EncodeJson([]byte(`{"a":{"#comment":"café"}}`)); err != nil {
+		t.Error(err)
+	} else if want := "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<a><!--caf\xe9--></a>"; b.String() != want {
+		t.Errorf("got %q want %q", b.String(), want)
+	}
+
+	if _, err := JsonToXml([]byte(`{"a":{"#text":"x","#comment":"c"}}`)); err == nil {
+		t.Error("no error for #text with #comment")
+	}
+}
@@ -0,0 +1,119 @@
+package j2x
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNamePolicy(t *testing.T) {
+	m := map[string]interface{}{
+		"doc": map[string]interface{}{
+			"2nd item": 1,
+			"a/b":      map[string]interface{}{"-x y": 2},
+			"":         3,
+			"ok":       4,
+		},
+	}
+
+	var tests = []struct {
+		opts []Option
+		want string
+	}{
+		{[]Option{Names(NameReplace)},
+			`<doc><_>3</_><_2nd_item>1</_2nd_item><a_b x_y="2"/><ok>4</ok></doc>`},
+		{[]Option{Names(NameReplace), NameSubstitute("x")},
+			`<doc><x>3</x><x2ndxitem>1</x2ndxitem><axb xxy="2"/><ok>4</ok></doc>`},
+		{[]Option{Names(NameAsAttr)},
+			`<doc><item key="">3</item><item key="2nd item">1</item><item key="a/b" x_y="2"/><ok>4</ok></doc>`},
+		{[]Option{Names(NameAsAttr), NameElement("field", "name")},
+			`<doc><field name="">3</field><field name="2nd item">1</field><field name="a/b" x_y="2"/><ok>4</ok></doc>`},
+	}
+
+	fmt.Println("\nTestNamePolicy ...")
+	for i, tt := range tests {
+		var b bytes.Buffer
+		if err := NewEncoder(&b, tt.opts...).Encode(m); err != nil {
+			t.Error(i, "err:", err.Error())
+		}
+		fmt.Println("v:", b.String())
+		if b.String() != tt.want {
+			t.Errorf("%d: got %s want %s", i, b.String(), tt.want)
+		}
+	}
+
+	for _, key := range []string{"2nd item", "a/b", "", "x y", "{urn:x}1a"} {
+		_, err := MapToXml(map[string]interface{}{"doc": map[string]interface{}{key: 1}})
+		var ee *EncodeError
+		if !errors.As(err, &ee) || ee.Kind != ErrInvalidName {
+			t.Errorf("%q: got %v", key, err)
+			continue
+		}
+		fmt.Println("err:", err.Error())
+	}
+	v, err := MapToXml(map[string]interface{}{"{urn:x}1a": 1}, "doc")
+	if err == nil {
+		t.Errorf("no error for Clark name: %s", v)
+	}
+
+	// the substitute doesn't make a valid name
+	var b bytes.Buffer
+	if err := NewEncoder(&b, Names(NameReplace), NameSubstitute("-")).Encode(map[string]interface{}{"": 1}); err == nil {
+		t.Errorf("no error for substitute: %s", b.String())
+	}
+
+	b.Reset()
+	if err := NewEncoder(&b, Names(NameReplace)).Encode(map[string]interface{}{"{urn:x}1a": 1}); err != nil {
+		t.Error("err:", err.Error())
+	}
+	if want := `<ns1:_1a xmlns:ns1="urn:x">1</ns1:_1a>`; b.String() != want {
+		t.Errorf("Clark: got %s want %s", b.String(), want)
+	}
+}
+
+func TestDuplicateAttr(t *testing.T) {
+	var tests = []struct {
+		json string
+		opts []Option
+		path string
+	}{
+		{`{"r":{"a b":{"-key":"z"}}}`, []Option{Names(NameAsAttr)}, "/r/a b/-key"},
+		{`{"r":{"-a b":"1","-a_b":"2"}}`, []Option{Names(NameReplace)}, "/r/-a_b"},
+		{`{"r":{"-xmlns:x":"urn:x","-x:a":"1","-{urn:x}a":"2"}}`, nil, "/r/-{urn:x}a"},
+	}
+
+	fmt.Println("\nTestDuplicateAttr ...")
+	for _, tt := range tests {
+		for _, transcode := range []bool{false, true} {
+			var b bytes.Buffer
+			e := NewEncoder(&b, tt.opts...)
+			var err error
+			if transcode {
+				err = e.Transcode(strings.NewReader(tt.json))
+			} else {
+				err = e.EncodeJson([]byte(tt.json))
+			}
+			ee, ok := err.(*EncodeError)
+			if !ok || ee.Kind != ErrInvalidName || ee.Path != tt.path {
+				t.Errorf("%s (transcode: %t): got %v %s want ErrInvalidName at %s", tt.json, transcode, err, b.String(), tt.path)
+				continue
+			}
+			if !transcode {
+				fmt.Println(err)
+			}
+		}
+	}
+}
+
+func TestIsName(t *testing.T) {
+	for s, want := range map[string]bool{
+		"a": true, "_a1": true, "a-b.c": true, "soap:Body": true, "été": true, "日本": true,
+		"": false, "1a": false, "-a": false, ".a": false, "a b": false, "a/b": false, "a\xff": false,
+	} {
+		if got := isName(s); got != want {
+			t.Errorf("%q: got %v want %v", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package j2x
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestNamespaces(t *testing.T) {
+	const soap = "http://schemas.xmlsoap.org/soap/envelope/"
+	var tests = []struct {
+		m    map[string]interface{}
+		opts []Option
+		want string
+	}{
+		// declared prefix
+		{map[string]interface{}{"soap:Envelope": map[string]interface{}{"-xmlns:soap": soap, "soap:Body": "x"}}, nil,
+			`<soap:Envelope xmlns:soap="` + soap + `"><soap:Body>x</soap:Body></soap:Envelope>`},
+		// declared prefix used by an attribute
+		{map[string]interface{}{"a": map[string]interface{}{"-xmlns:x": "urn:x", "-x:id": 1, "-xml:lang": "en"}}, nil,
+			`<a x:id="1" xml:lang="en" xmlns:x="urn:x"/>`},
+		// auto-declared from the table
+		{map[string]interface{}{"soap:Envelope": map[string]interface{}{"soap:Body": "x"}}, []Option{Namespaces(map[string]string{"soap": soap})},
+			`<soap:Envelope xmlns:soap="` + soap + `"><soap:Body>x</soap:Body></soap:Envelope>`},
+		// Clark notation mapped to a prefix in scope
+		{map[string]interface{}{"e:a": map[string]interface{}{"-xmlns:e": "urn:e", "{urn:e}b": 1}}, nil,
+			`<e:a xmlns:e="urn:e"><e:b>1</e:b></e:a>`},
+		// Clark notation mapped to the default namespace
+		{map[string]interface{}{"a": map[string]interface{}{"-xmlns": "urn:d", "{urn:d}b": 1, "-{urn:d}c": 2}}, nil,
+			`<a xmlns:ns1="urn:d" xmlns="urn:d" ns1:c="2"><b>1</b></a>`},
+		// Clark notation mapped to a table prefix
+		{map[string]interface{}{"{" + soap + "}Envelope": map[string]interface{}{"{" + soap + "}Body": "x"}}, []Option{Namespaces(map[string]string{"soap": soap})},
+			`<soap:Envelope xmlns:soap="` + soap + `"><soap:Body>x</soap:Body></soap:Envelope>`},
+		// Clark notation with a generated prefix
+		{map[string]interface{}{"{urn:x}a": map[string]interface{}{"{urn:x}b": 1, "{urn:y}c": 2}}, nil,
+			`<ns1:a xmlns:ns1="urn:x"><ns1:b>1</ns1:b><ns2:c xmlns:ns2="urn:y">2</ns2:c></ns1:a>`},
+		// no check
+		{map[string]interface{}{"soap:Envelope": 1}, []Option{NamespaceCheck(false)}, `<soap:Envelope>1</soap:Envelope>`},
+	}
+
+	fmt.Println("\nTestNamespaces ...")
+	for i, tt := range tests {
+		var b bytes.Buffer
+		if err := NewEncoder(&b, tt.opts...).Encode(tt.m); err != nil {
+			t.Error(i, "err:", err.Error())
+		}
+		fmt.Println("v:", b.String())
+		if b.String() != tt.want {
+			t.Errorf("%d: got %s want %s", i, b.String(), tt.want)
+		}
+	}
+}
+
+func TestNamespaceErrors(t *testing.T) {
+	var tests = []map[string]interface{}{
+		{"soap:Envelope": "x"},
+		{"a": map[string]interface{}{"-x:id": 1}},
+		{"x:a": map[string]interface{}{"-xmlns:y": "urn:y", "-y:id": 1}},
+		{"{urn:x": 1},
+	}
+
+	fmt.Println("\nTestNamespaceErrors ...")
+	for i, m := range tests {
+		_, err := MapToXml(m)
+		if err == nil {
+			t.Error(i, "no error for:", m)
+			continue
+		}
+		fmt.Println("err:", err.Error())
+	}
+}
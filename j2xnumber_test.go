@@ -0,0 +1,93 @@
+package j2x
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestJsonNumber(t *testing.T) {
+	doc := `{ "n":{ "-id":12345678901234567890, "price":19.990, "big":1e400, "small":-0.000000000000000000001 } }`
+	want := `<n id="12345678901234567890"><big>1e400</big><price>19.990</price><small>-0.000000000000000000001</small></n>`
+
+	fmt.Println("\nTestJsonNumber ...")
+	x, err := JsonToXml([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println(string(x))
+	if string(x) != want {
+		t.Error("JsonToXml:", string(x))
+	}
+
+	var b bytes.Buffer
+	if err := JsonStreamToXml(strings.NewReader(doc), &b); err != nil {
+		t.Fatal(err)
+	}
+	if want := `<n id="12345678901234567890"><price>19.990</price><big>1e400</big><small>-0.000000000000000000001</small></n>`; b.String() != want {
+		t.Error("JsonStreamToXml:", b.String())
+	}
+
+	om, err := JsonToOrderedMap([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x, _ := OrderedMapToXml(om); !strings.Contains(string(x), `id="12345678901234567890"`) {
+		t.Error("OrderedMapToXml:", string(x))
+	}
+
+	m, _, err := JsonReaderToMap(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, ok := m["n"].(map[string]interface{})["-id"].(json.Number); !ok || id != "12345678901234567890" {
+		t.Errorf("JsonReaderToMap: %T %v", id, id)
+	}
+}
+
+func TestNumericTypes(t *testing.T) {
+	bi, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	bf, _, _ := big.ParseFloat("3.14159265358979323846264338327950288", 10, 200, big.ToNearestEven)
+	m := map[string]interface{}{
+		"-a":  int8(-8),
+		"-b":  uint64(18446744073709551615),
+		"-c":  bi,
+		"i16": int16(-16),
+		"u":   uint(7),
+		"u8":  uint8(8),
+		"u16": uint16(16),
+		"u32": uint32(32),
+		"bf":  bf,
+		"f":   1234567.0,
+		"f32": float32(0.1),
+	}
+
+	fmt.Println("\nTestNumericTypes ...")
+	var b bytes.Buffer
+	if err := NewEncoder(&b, RootTag("r")).Encode(m); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println(b.String())
+	want := `<r a="-8" b="18446744073709551615" c="123456789012345678901234567890"><bf>3.14159265358979323846264338327950288</bf>` +
+		`<f>1.234567e+06</f><f32>0.1</f32><i16>-16</i16><u>7</u><u16>16</u16><u32>32</u32><u8>8</u8></r>`
+	if b.String() != want {
+		t.Errorf("got:  %s\nwant: %s", b.String(), want)
+	}
+	if fmt.Sprintf("%v", 1234567.0) != "1.234567e+06" {
+		t.Error("default float format differs from fmt")
+	}
+
+	b.Reset()
+	if err := NewEncoder(&b, FloatFormat('f', 2)).Encode(map[string]interface{}{"f": 1234567.0, "-g": float32(0.125)}); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != `<doc g="0.12"><f>1234567.00</f></doc>` {
+		t.Error("FloatFormat:", b.String())
+	}
+	if err := NewEncoder(&b, FloatFormat('z', 2)).Encode(m); err == nil {
+		t.Error("no error for invalid float format")
+	}
+}
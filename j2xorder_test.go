@@ -0,0 +1,197 @@
+package j2x
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestSortedKeys(t *testing.T) {
+	m := map[string]interface{}{
+		"doc": map[string]interface{}{"-z": 1, "-a": 2, "zz": "one", "aa": "two", "mm": []interface{}{3, 4}},
+	}
+	want := `<doc a="2" z="1"><aa>two</aa><mm>3</mm><mm>4</mm><zz>one</zz></doc>`
+
+	fmt.Println("\nTestSortedKeys ...")
+	for i := 0; i < 20; i++ {
+		v, err := MapToXml(m)
+		if err != nil {
+			t.Fatal("err:", err.Error())
+		}
+		if string(v) != want {
+			t.Fatalf("got %s want %s", v, want)
+		}
+	}
+
+	v, err := MapToXmlIndent(m, "", " ")
+	if err != nil {
+		t.Fatal("err:", err.Error())
+	}
+	fmt.Printf("v:\n%s", string(v))
+	wantIndent := "<doc a=\"2\" z=\"1\">\n <aa>two</aa>\n <mm>3</mm>\n <mm>4</mm>\n <zz>one</zz>\n</doc>\n"
+	if string(v) != wantIndent {
+		t.Errorf("got %q want %q", v, wantIndent)
+	}
+}
+
+func TestJsonKeyOrder(t *testing.T) {
+	s := `{ "doc":{ "-z":1, "-a":2, "zz":"one", "aa":{ "y":true, "x":false }, "mm":[ { "c":1, "b":2 } ] } }`
+
+	fmt.Println("\nTestJsonKeyOrder ...", s)
+	var b bytes.Buffer
+	err := NewEncoder(&b, Ordering(JsonKeyOrder)).EncodeJson([]byte(s))
+	if err != nil {
+		t.Fatal("err:", err.Error())
+	}
+	fmt.Println("v:", b.String())
+	want := `<doc z="1" a="2"><zz>one</zz><aa><y>true</y><x>false</x></aa><mm><c>1</c><b>2</b></mm></doc>`
+	if b.String() != want {
+		t.Errorf("got %s want %s", b.String(), want)
+	}
+
+	c := NewConverter(bytes.NewReader([]byte(s)), Ordering(JsonKeyOrder))
+	if !c.Next() {
+		t.Fatal("err:", c.Err())
+	}
+	if string(c.XML()) != want {
+		t.Errorf("converter: got %s want %s", c.XML(), want)
+	}
+
+	b.Reset()
+	if err = NewEncoder(&b, Ordering(JsonKeyOrder), Indent("", "  ")).EncodeJson([]byte(s)); err != nil {
+		t.Fatal("err:", err.Error())
+	}
+	fmt.Printf("v:\n%s", b.String())
+
+	// the package-level functions encode the keys sorted
+	v, err := JsonToXml([]byte(s))
+	if err != nil {
+		t.Fatal("err:", err.Error())
+	}
+	if want := `<doc a="2" z="1"><aa><x>false</x><y>true</y></aa><mm><b>2</b><c>1</c></mm><zz>one</zz></doc>`; string(v) != want {
+		t.Errorf("JsonToXml: got %s want %s", v, want)
+	}
+
+	if _, err = JsonToXml([]byte(`{ "a":1 } x`)); err == nil {
+		t.Error("no error for trailing data")
+	}
+}
+
+func TestOrderedMap(t *testing.T) {
+	om := NewOrderedMap()
+	om.Set("zz", "one")
+	om.Set("-b", 1)
+	om.Set("aa", "two")
+	om.Set("-a", 2)
+	inner := NewOrderedMap()
+	inner.Set("y", true)
+	inner.Set("x", false)
+	om.Set("mm", inner)
+	om.Set("zz", "three")
+	om.Delete("aa")
+
+	fmt.Println("\nTestOrderedMap ...")
+	v, err := OrderedMapToXml(om, "doc")
+	if err != nil {
+		t.Fatal("err:", err.Error())
+	}
+	fmt.Println("v:", string(v))
+	want := `<doc b="1" a="2"><zz>three</zz><mm><y>true</y><x>false</x></mm></doc>`
+	if string(v) != want {
+		t.Errorf("got %s want %s", v, want)
+	}
+
+	// OrderedMap values nested in a map[string]interface{}
+	v, err = MapToXml(map[string]interface{}{"doc": om})
+	if err != nil {
+		t.Fatal("err:", err.Error())
+	}
+	if string(v) != want {
+		t.Errorf("nested: got %s want %s", v, want)
+	}
+
+	v, err = Marshal(om, "doc")
+	if err != nil {
+		t.Fatal("err:", err.Error())
+	}
+	if string(v) != want {
+		t.Errorf("Marshal: got %s want %s", v, want)
+	}
+
+	v, err = OrderedMapToXmlIndent(om, "", "  ", "doc")
+	if err != nil {
+		t.Fatal("err:", err.Error())
+	}
+	fmt.Printf("v:\n%s", string(v))
+
+	j, err := om.MarshalJSON()
+	if err != nil {
+		t.Fatal("err:", err.Error())
+	}
+	if want := `{"zz":"three","-b":1,"-a":2,"mm":{"y":true,"x":false}}`; string(j) != want {
+		t.Errorf("MarshalJSON: got %s want %s", j, want)
+	}
+}
+
+func TestJsonToOrderedMap(t *testing.T) {
+	s := `{ "Envelope":{ "Header":{ "id":7 }, "Body":{ "b":[ { "z":1, "y":2 } ], "a":null } } }`
+
+	fmt.Println("\nTestJsonToOrderedMap ...", s)
+	om, err := JsonToOrderedMap([]byte(s))
+	if err != nil {
+		t.Fatal("err:", err.Error())
+	}
+	v, err := OrderedMapToXml(om)
+	if err != nil {
+		t.Fatal("err:", err.Error())
+	}
+	fmt.Println("v:", string(v))
+	want := `<Envelope><Header><id>7</id></Header><Body><b><z>1</z><y>2</y></b><a/></Body></Envelope>`
+	if string(v) != want {
+		t.Errorf("got %s want %s", v, want)
+	}
+
+	r := bytes.NewReader([]byte(s + s))
+	for i := 0; i < 2; i++ {
+		om, jb, err := JsonReaderToOrderedMap(r)
+		if err != nil {
+			t.Fatal("err:", err.Error())
+		}
+		fmt.Println("jb:", string(*jb), "keys:", om.Keys())
+	}
+
+	om = new(OrderedMap)
+	if err = om.UnmarshalJSON([]byte(s)); err != nil {
+		t.Fatal("err:", err.Error())
+	}
+	if v, _ = OrderedMapToXml(om); string(v) != want {
+		t.Errorf("UnmarshalJSON: got %s want %s", v, want)
+	}
+}
+
+func TestNilOrderedMap(t *testing.T) {
+	var om *OrderedMap
+
+	fmt.Println("\nTestNilOrderedMap ...")
+	for _, tt := range []struct {
+		v    interface{}
+		want string
+	}{
+		{map[string]interface{}{"a": om}, `<a/>`},
+		{map[string]interface{}{"r": map[string]interface{}{"a": om, "b": 1}}, `<r><a/><b>1</b></r>`},
+		{om, `<doc/>`},
+	} {
+		v, err := Marshal(tt.v)
+		if err != nil {
+			t.Error("err:", err.Error())
+			continue
+		}
+		fmt.Println("v:", string(v))
+		if string(v) != tt.want {
+			t.Errorf("got %s want %s", v, tt.want)
+		}
+	}
+	if v, err := OrderedMapToXml(om); err != nil || string(v) != `<doc/>` {
+		t.Errorf("OrderedMapToXml: got %s %v", v, err)
+	}
+}
@@ -0,0 +1,100 @@
+package j2x
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// parallelData returns n JSON records; the records with an index in bad can't be encoded.
+func parallelData(n int, bad ...int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		v := fmt.Sprintf(`{ "rec":{ "-id":%d, "data":"%s" } }`, i, strings.Repeat("x", i%50))
+		for _, j := range bad {
+			if i == j {
+				v = fmt.Sprintf(`{ "rec":{ "-id":[ %d ] } }`, i)
+			}
+		}
+		b.WriteString(v + "\n")
+	}
+	return b.String()
+}
+
+func TestConvertParallel(t *testing.T) {
+	data := parallelData(500)
+	var want bytes.Buffer
+	c := NewConverter(strings.NewReader(data))
+	for c.Next() {
+		want.Write(c.XML())
+	}
+	if err := c.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	fmt.Println("\nTestConvertParallel ...")
+	for _, workers := range []int{0, 1, 4, 16} {
+		var got bytes.Buffer
+		if err := ConvertParallel(strings.NewReader(data), &got, workers, FirstError); err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != want.String() {
+			t.Errorf("workers %d: output differs", workers)
+		}
+	}
+}
+
+func TestConvertParallelErrors(t *testing.T) {
+	data := parallelData(200, 37, 150)
+
+	fmt.Println("\nTestConvertParallelErrors ...")
+	var b bytes.Buffer
+	err := ConvertParallel(strings.NewReader(data), &b, 8, FirstError)
+	fmt.Println("first error:", err)
+	var re *RecordError
+	if !errors.As(err, &re) || re.Index != 37 {
+		t.Fatal("first error:", err)
+	}
+	if n := strings.Count(b.String(), "<rec "); n != 37 {
+		t.Error("records written:", n)
+	}
+
+	b.Reset()
+	err = ConvertParallel(strings.NewReader(data), &b, 8, CollectErrors)
+	fmt.Println("collected errors:", err)
+	var errs RecordErrors
+	if !errors.As(err, &errs) || len(errs) != 2 || errs[0].Index != 37 || errs[1].Index != 150 {
+		t.Fatal("collected errors:", err)
+	}
+	if n := strings.Count(b.String(), "<rec "); n != 198 {
+		t.Error("records written:", n)
+	}
+
+	b.Reset()
+	err = ConvertParallel(strings.NewReader(`{ "a":1 } { "b": }`), &b, 2, CollectErrors)
+	if !errors.As(err, &errs) || len(errs) != 1 || !isSyntaxError(errs[0].Err) || b.String() != "<a>1</a>" {
+		t.Error("syntax error:", err, b.String())
+	}
+}
+
+// failWriter fails after n writes.
+type failWriter struct {
+	n int
+}
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	if w.n--; w.n < 0 {
+		return 0, errors.New("write failed")
+	}
+	return len(p), nil
+}
+
+func TestConvertParallelWriteError(t *testing.T) {
+	data := parallelData(10000)
+	err := ConvertParallel(strings.NewReader(data), &failWriter{2}, 4, CollectErrors)
+	if err == nil || err.Error() != "write failed" {
+		t.Error("write error:", err)
+	}
+}
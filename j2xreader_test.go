@@ -85,6 +85,7 @@ func TestInputError(t *testing.T) {
 
 	fmt.Println("\ndata for structs:", string(data))
 	r := bytes.NewReader(data)
+	var errs int
 	for {
 		v := new(tstruct)
 		_, err := JsonReaderToStruct(r,v)
@@ -93,8 +94,79 @@ func TestInputError(t *testing.T) {
 				break
 			}
 			fmt.Println("err:",err.Error())
+			errs++
 		}
 		fmt.Println("v:",v)
 	}
+	// the string "Key1", the syntax error at ':' that skips the rest of the line and the
+	// unterminated object on the next line
+	if errs != 3 {
+		t.Errorf("got %d errors want 3", errs)
+	}
+}
+
+
+// onlyReader hides the io.ByteScanner methods of a reader.
+type onlyReader struct {
+	r io.Reader
+}
+
+func (r *onlyReader) Read(p []byte) (int, error) {
+	return r.r.Read(p)
+}
+
+// readerFunc is an io.Reader that isn't comparable.
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
 }
 
+func TestReaderFraming(t *testing.T) {
+	data := "\xEF\xBB\xBF" + `{"a":"quoted \" } brace"} [1,{"b":[2]}]
+"string" 12.5 true null
+{"c":"\\"}{"d":{}},[]` + "\n"
+	want := []string{`{"a":"quoted \" } brace"}`, `[1,{"b":[2]}]`, `"string"`, `12.5`, `true`, `null`, `{"c":"\\"}`, `{"d":{}}`, `[]`}
+
+	fmt.Println("\nTestReaderFraming ...")
+	for _, r := range []io.Reader{bytes.NewReader([]byte(data)), &onlyReader{bytes.NewReader([]byte(data))}} {
+		var got []string
+		for {
+			_, jb, err := JsonReaderToXml(r)
+			if err != nil {
+				if err != io.EOF {
+					t.Error("err:", err)
+				}
+				break
+			}
+			got = append(got, string(*jb))
+		}
+		fmt.Println("values:", got)
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("got %q want %q", got, want)
+		}
+	}
+
+	// a reader that isn't comparable
+	br := bytes.NewReader([]byte(`{"a":1} {"b":2} {"c":3}`))
+	var fr readerFunc = br.Read
+	var got []string
+	for {
+		_, jb, err := JsonReaderToMap(fr)
+		if err != nil {
+			if err != io.EOF {
+				t.Error("err:", err)
+			}
+			break
+		}
+		got = append(got, string(*jb))
+	}
+	if fmt.Sprint(got) != `[{"a":1} {"b":2} {"c":3}]` {
+		t.Errorf("readerFunc: got %q", got)
+	}
+
+	_, _, err := JsonReaderToMap(bytes.NewReader([]byte(`{"a":[1,2}`)))
+	if err == nil || err == io.EOF {
+		t.Error("no error for truncated value:", err)
+	}
+}
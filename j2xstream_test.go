@@ -0,0 +1,161 @@
+package j2x
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+var streamDocs = []string{
+	`{ "a":1 }`,
+	`{ "a":1, "b":"two" }`,
+	`{}`,
+	`[ 1, { "x":2 }, [ 3, 4 ] ]`,
+	`"scalar < value"`,
+	`null`,
+	`{ "list":[ 1, 2 ] }`,
+	`{ "list":[ { "-id":1, "v":"a" }, [ "x", [ "y" ] ] ], "n":null }`,
+	`{ "doc":{ "c":{ "d":true }, "-attr":"late", "e":[], "f":{} } }`,
+	`{ "t":{ "-a":1, "#text":"text & more" } }`,
+	`{ "t":{ "#text":"text", "-a":1 } }`,
+	`{ "ns":{ "-xmlns:x":"urn:x", "x:a":{ "-x:b":1 }, "{urn:y}c":2 } }`,
+	`{ "r":{ "-{urn:a}d":"a", "{urn:a}b":{ "{urn:a}c":1, "-{urn:b}e":2 } } }`,
+	`{ "{urn:a}r":{ "{urn:a}b":1, "-xmlns:y":"urn:y" } }`,
+	`{ "bad key":{ "-2nd":1 } }`,
+	`{ "-id":"1", "name":"x" }`,
+	`{ "#text":"x", "-a":"1" }`,
+	`{ "-id":"1" }`,
+	`{ "#cdata":"x" }`,
+}
+
+func TestTranscode(t *testing.T) {
+	var opts = [][]Option{
+		nil,
+		{Indent("", "  ")},
+		{Indent(">", "\t"), GoXmlEmptyElemSyntax(true)},
+		{RootTag("root"), WrapArrays(SingularItemName)},
+		{WrapArrays(FixedItemName("i")), Indent("", " ")},
+		{Names(NameAsAttr), Escaping(EscapeMinimal)},
+		{Names(NameReplace), MaxBuffer(200), Indent("", "  ")},
+		{NamespaceCheck(false), Declaration("", "ISO-8859-1", "")},
+	}
+
+	fmt.Println("\nTestTranscode ...")
+	for i, o := range opts {
+		for _, doc := range streamDocs {
+			var want, got bytes.Buffer
+			werr := NewEncoder(&want, append([]Option{Ordering(JsonKeyOrder)}, o...)...).EncodeJson([]byte(doc))
+			gerr := NewEncoder(&got, o...).Transcode(strings.NewReader(doc))
+			if fmt.Sprint(werr) != fmt.Sprint(gerr) {
+				t.Errorf("opts %d %s: err %v want %v", i, doc, gerr, werr)
+				continue
+			}
+			if werr == nil && got.String() != want.String() {
+				t.Errorf("opts %d %s:\ngot:  %s\nwant: %s", i, doc, got.String(), want.String())
+			}
+		}
+	}
+
+	// a top-level attribute or text key isn't a root element
+	for doc, want := range map[string]string{
+		`{"-id":"1","name":"x"}`: `<doc id="1"><name>x</name></doc>`,
+		`{"#text":"x","-a":"1"}`: `<doc a="1">x</doc>`,
+	} {
+		var b bytes.Buffer
+		if err := JsonStreamToXml(strings.NewReader(doc), &b); err != nil || b.String() != want {
+			t.Errorf("%s: got %s, %v want %s", doc, b.String(), err, want)
+		}
+	}
+
+	var b bytes.Buffer
+	doc := `{ "doc":{ "c":{ "d":true }, "-attr":"late" } }`
+	if err := JsonStreamToXml(strings.NewReader(doc), &b); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println("late attribute:", b.String())
+	if b.String() != `<doc attr="late"><c><d>true</d></c></doc>` {
+		t.Error("late attribute:", b.String())
+	}
+}
+
+func TestTranscodeBufferLimit(t *testing.T) {
+	var tests = []struct {
+		json string
+		path string
+	}{
+		{`{ "doc":{ "c":"0123456789", "-attr":"late" } }`, "/doc/-attr"},
+		{`{ "doc":{ "c":"0123456789" }, "more":1 }`, "/more"},
+		{`{ "doc":[ { "c":"0123456789", "-attr":"late" } ] }`, "/doc[0]/-attr"},
+	}
+
+	fmt.Println("\nTestTranscodeBufferLimit ...")
+	for _, tt := range tests {
+		var b bytes.Buffer
+		err := NewEncoder(&b, MaxBuffer(10)).Transcode(strings.NewReader(tt.json))
+		fmt.Println("err:", err)
+		var ee *EncodeError
+		if !errors.As(err, &ee) || ee.Kind != ErrBufferLimit || ee.Path != tt.path {
+			t.Errorf("%s: got %v want buffer limit at %s", tt.json, err, tt.path)
+		}
+	}
+
+	// the root is re-encoded within the limit
+	var b bytes.Buffer
+	if err := NewEncoder(&b, MaxBuffer(100)).Transcode(strings.NewReader(tests[1].json)); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != `<doc><doc><c>0123456789</c></doc><more>1</more></doc>` {
+		t.Error("root:", b.String())
+	}
+
+	if err := NewEncoder(&b, MaxBuffer(-1)).Transcode(strings.NewReader("{}")); err == nil {
+		t.Error("no error for negative buffer size")
+	}
+	if err := JsonStreamToXml(strings.NewReader(`{ "a":[ 1, 2`), &b); err != io.ErrUnexpectedEOF {
+		t.Error("truncated input:", err)
+	}
+}
+
+// countingWriter counts the bytes written.
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	atomic.AddInt64(&w.n, int64(len(p)))
+	return len(p), nil
+}
+
+func TestTranscodeBounded(t *testing.T) {
+	const n = 20000
+	w := new(countingWriter)
+	var midway int64
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte(`{ "export":{ "-version":1, "record":[`))
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				pw.Write([]byte(","))
+			}
+			if i == n/2 {
+				midway = atomic.LoadInt64(&w.n)
+			}
+			fmt.Fprintf(pw, `{ "-id":%d, "name":"record %d", "tags":[ "x", "y" ] }`, i, i)
+		}
+		pw.Write([]byte(`] } }`))
+		pw.Close()
+	}()
+
+	if err := NewEncoder(w, MaxBuffer(1024)).Transcode(pr); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println("\nTestTranscodeBounded ... bytes written midway:", midway, "total:", w.n)
+	// the XML of the records read so far has been written
+	if midway < w.n/3 {
+		t.Error("bytes written midway:", midway)
+	}
+}
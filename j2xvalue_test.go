@@ -0,0 +1,127 @@
+package j2x
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+type level int
+
+func (l level) String() string {
+	return [...]string{"low", "high"}[l]
+}
+
+type code string
+
+type celsius float32
+
+// badText can't be encoded; xml.Marshal also uses MarshalText.
+type badText struct {
+	V int
+}
+
+func (badText) MarshalText() ([]byte, error) {
+	return nil, errors.New("bad text")
+}
+
+func TestScalarValues(t *testing.T) {
+	when := time.Date(2024, 2, 29, 13, 14, 15, 500, time.UTC)
+	n := 42
+	m := map[string]interface{}{
+		"-when":  when,
+		"-level": level(1),
+		"ip":     net.IPv4(10, 0, 0, 1),
+		"code":   code("a<b"),
+		"temp":   celsius(21.5),
+		"ptr":    &n,
+		"c":      complex(1, -2),
+		"t":      map[string]interface{}{"-at": &when, "#text": level(0)},
+	}
+
+	fmt.Println("\nTestScalarValues ...")
+	var b bytes.Buffer
+	if err := NewEncoder(&b, RootTag("r")).Encode(m); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println(b.String())
+	want := `<r level="high" when="2024-02-29T13:14:15.0000005Z"><c>(1-2i)</c>` +
+		`<code>a&lt;b</code><ip>10.0.0.1</ip><ptr>42</ptr><t at="2024-02-29T13:14:15.0000005Z">low</t><temp>21.5</temp></r>`
+	if b.String() != want {
+		t.Errorf("got:  %s\nwant: %s", b.String(), want)
+	}
+
+	b.Reset()
+	if err := NewEncoder(&b, TimeLayout("2006-01-02")).Encode(map[string]interface{}{"d": when, "-d": when}); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != `<doc d="2024-02-29"><d>2024-02-29</d></doc>` {
+		t.Error("TimeLayout:", b.String())
+	}
+
+	if _, err := MapToXml(map[string]interface{}{"a": map[string]interface{}{"-b": badText{1}}}); err == nil {
+		t.Error("no error for attribute that can't be marshaled as text")
+	}
+}
+
+func TestUnencodable(t *testing.T) {
+	m := map[string]interface{}{"r": map[string]interface{}{"a": 1, "bad": []interface{}{2, badText{3}}, "c": make(chan int)}}
+	var tests = []struct {
+		opts []Option
+		want string
+	}{
+		{[]Option{Unencodable(ValueSkip)}, `<r><a>1</a><bad>2</bad></r>`},
+		{[]Option{Unencodable(ValuePlaceholder)}, `<r><a>1</a><bad>2</bad><bad>UNKNOWN</bad><c>UNKNOWN</c></r>`},
+		{[]Option{Unencodable(ValuePlaceholder), Placeholder("n/a")}, `<r><a>1</a><bad>2</bad><bad>n/a</bad><c>n/a</c></r>`},
+		{[]Option{Fallback(func(path string, v interface{}) (string, error) {
+			return fmt.Sprintf("%s %T", path, v), nil
+		})}, `<r><a>1</a><bad>2</bad><bad>/r/bad[1] j2x.badText</bad><c>/r/c chan int</c></r>`},
+	}
+
+	fmt.Println("\nTestUnencodable ...")
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := NewEncoder(&b, tt.opts...).Encode(m); err != nil {
+			t.Error(err)
+			continue
+		}
+		fmt.Println(b.String())
+		if b.String() != tt.want {
+			t.Errorf("got:  %s\nwant: %s", b.String(), tt.want)
+		}
+	}
+
+	for _, opt := range []Option{Unencodable(ValueError), Fallback(func(string, interface{}) (string, error) {
+		return "", errors.New("no fallback")
+	})} {
+		err := NewEncoder(new(bytes.Buffer), opt).Encode(m)
+		fmt.Println("err:", err)
+		var ee *EncodeError
+		if !errors.As(err, &ee) || ee.Kind != ErrMarshal || ee.Path != "/r/bad[1]" {
+			t.Error("error:", err)
+		}
+	}
+	if _, err := MapToXml(m); err == nil {
+		t.Error("no error from MapToXml")
+	}
+
+	// text values
+	var b bytes.Buffer
+	tm := map[string]interface{}{"a": map[string]interface{}{"#text": make(chan int)}, "b": map[string]interface{}{"#text": nil}}
+	if err := NewEncoder(&b, Unencodable(ValuePlaceholder)).Encode(tm); err != nil || b.String() != `<doc><a>UNKNOWN</a><b></b></doc>` {
+		t.Error("text values:", b.String(), err)
+	}
+	for j, path := range map[string]string{`{"a":{"#text":{"b":1}}}`: "/a/#text", `{"a":{"#cdata":{}}}`: "/a/#cdata", `{"a":{"#text":[1]}}`: "/a/#text"} {
+		for _, o := range []KeyOrder{SortedKeys, JsonKeyOrder} {
+			b.Reset()
+			err := NewEncoder(&b, Ordering(o)).EncodeJson([]byte(j))
+			var ee *EncodeError
+			if !errors.As(err, &ee) || ee.Kind != ErrTextKey || ee.Path != path {
+				t.Errorf("%s: got %s, %v", j, b.String(), err)
+			}
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package j2x
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestXmlToMap(t *testing.T) {
+	var tests = []struct {
+		xml  string
+		json string
+	}{
+		{`<a>1</a>`, `{"a":"1"}`},
+		{`<a/>`, `{"a":""}`},
+		{`<?xml version="1.0"?><!-- c --><a x="1 &amp; 2"><b>t</b><b>u &lt; v</b><c/></a>`, `{"a":{"-x":"1 & 2","b":["t","u < v"],"c":""}}`},
+		{"<a><b>1</b>\n<c>2</c> <b>3</b></a>", `{"a":{"#content":[{"b":"1"},{"c":"2"},{"b":"3"}]}}`},
+		{`<a x="1">text</a>`, `{"a":{"#text":"text","-x":"1"}}`},
+		{"<a>\n  <b>1</b>\n</a>", `{"a":{"b":"1"}}`},
+		{`<s:e xmlns:s="urn:s" xmlns="urn:d"><s:b s:c="1"/></s:e>`, `{"s:e":{"-xmlns":"urn:d","-xmlns:s":"urn:s","s:b":{"-s:c":"1"}}}`},
+		{"<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><a>caf\xe9</a>", `{"a":"café"}`},
+		{"<?xml version=\"1.0\" encoding=\"Windows-1252\"?><a>\x80</a>", `{"a":"€"}`},
+	}
+
+	fmt.Println("\nTestXmlToMap ...")
+	for _, tt := range tests {
+		j, err := XmlToJson([]byte(tt.xml))
+		if err != nil {
+			t.Error(tt.xml, err)
+			continue
+		}
+		fmt.Println(string(j))
+		if string(j) != tt.json {
+			t.Errorf("%s: got %s want %s", tt.xml, j, tt.json)
+		}
+	}
+
+	for _, x := range []string{`<a>`, `<a></b>`, `<a x="1" x="2"/>`, ``} {
+		if _, err := XmlToMap([]byte(x)); err == nil {
+			t.Error("no error for:", x)
+		}
+	}
+}
+
+func TestXmlReaderToMap(t *testing.T) {
+	data := `<a>1</a>
+<b x="2"/><c><d/></c>`
+
+	fmt.Println("\nTestXmlReaderToMap ...")
+	sr := strings.NewReader(data)
+	var fr readerFunc = sr.Read
+	for _, r := range []io.Reader{strings.NewReader(data), &onlyReader{strings.NewReader(data)}, fr} {
+		var got []string
+		for {
+			m, raw, err := XmlReaderToMap(r)
+			if err != nil {
+				if err != io.EOF {
+					t.Error(err)
+				}
+				break
+			}
+			got = append(got, fmt.Sprint(m, " ", strings.TrimSpace(string(*raw))))
+		}
+		fmt.Println(got)
+		if want := `[map[a:1] <a>1</a> map[b:map[-x:2]] <b x="2"/> map[c:map[d:]] <c><d/></c>]`; fmt.Sprint(got) != want {
+			t.Errorf("got %s want %s", got, want)
+		}
+	}
+}
+
+// randomXml returns a random XML element; the tags are from a small set so that
+// elements are repeated.
+func randomXml(rnd *rand.Rand, depth int) string {
+	names := []string{"a", "b", "c", "x:d"}
+	texts := []string{"", "1", "two words", "a < b & c > d", `"quoted" 'text'`, " padded ", "é€😀", "line\nbreak"}
+	tag := names[rnd.Intn(len(names))]
+
+	var b strings.Builder
+	b.WriteString("<" + tag)
+	if depth == 0 {
+		b.WriteString(` xmlns:x="urn:x"`)
+	}
+	for i, n := range rnd.Perm(3)[:rnd.Intn(3)] {
+		b.WriteString(fmt.Sprintf(` %s="%s"`, []string{"id", "x:ref", "lang"}[n], escapeString(texts[(i+n)%len(texts)], true, false)))
+	}
+	b.WriteString(">")
+	if depth < 4 && rnd.Intn(3) > 0 {
+		for i := rnd.Intn(4); i >= 0; i-- {
+			if rnd.Intn(4) == 0 {
+				// mixed content
+				b.WriteString(escapeString(texts[1+rnd.Intn(len(texts)-1)], false, false))
+			}
+			b.WriteString(randomXml(rnd, depth+1))
+			if rnd.Intn(2) == 0 {
+				b.WriteString("\n  ")
+			}
+		}
+	} else {
+		b.WriteString(escapeString(texts[rnd.Intn(len(texts))], false, false))
+	}
+	b.WriteString("</" + tag + ">")
+	return b.String()
+}
+
+// xmlTokens returns the tokens of the XML doc x: elements with their attributes in sorted
+// order and the text, without the white space between elements.
+func xmlTokens(x []byte) ([]string, error) {
+	d := xml.NewDecoder(bytes.NewReader(x))
+	var toks []string
+	var text strings.Builder
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			return toks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if cd, ok := tok.(xml.CharData); ok {
+			text.Write(cd)
+			continue
+		}
+		if s := text.String(); strings.TrimSpace(s) != "" {
+			toks = append(toks, fmt.Sprintf("%q", s))
+		}
+		text.Reset()
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			attrs := make([]string, len(tok.Attr))
+			for i, a := range tok.Attr {
+				attrs[i] = fmt.Sprintf("%s=%q", xmlName(a.Name), a.Value)
+			}
+			sort.Strings(attrs)
+			toks = append(toks, "<"+xmlName(tok.Name)+" "+strings.Join(attrs, " ")+">")
+		case xml.EndElement:
+			toks = append(toks, "</"+xmlName(tok.Name)+">")
+		}
+	}
+}
+
+// TestXmlRoundTrip checks that MapToXml(XmlToMap(x)) is equivalent to x: it has the same
+// elements, attributes and text, in the same order.
+func TestXmlRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	fmt.Println("\nTestXmlRoundTrip ...")
+	for i := 0; i < 500; i++ {
+		x := randomXml(rnd, 0)
+		if i == 1 {
+			x = `<a><b>1</b><c>2</c><b>3</b></a>`
+		}
+		want, err := xmlTokens([]byte(x))
+		if err != nil {
+			t.Fatal(x, err)
+		}
+		m, err := XmlToMap([]byte(x))
+		if err != nil {
+			t.Fatal(x, err)
+		}
+		for _, encode := range []func(map[string]interface{}) ([]byte, error){
+			func(m map[string]interface{}) ([]byte, error) { return MapToXml(m) },
+			func(m map[string]interface{}) ([]byte, error) { return MapToXmlIndent(m, "", "  ") },
+		} {
+			x2, err := encode(m)
+			if err != nil {
+				t.Fatal(x, err)
+			}
+			got, err := xmlTokens(x2)
+			if err != nil {
+				t.Fatal(string(x2), err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("round trip of %s:\n%s\n%v\n%v", x, x2, want, got)
+			}
+		}
+		if i == 0 {
+			fmt.Println(x)
+		}
+	}
+
+	// JSON round trip; the keys are in sorted order, as json.Marshal() encodes them
+	j := []byte(`{"order":{"-id":"7","item":[{"#text":"x & y","-sku":"a"},"plain"],"note":""}}`)
+	x, err := JsonToXml(j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j2, err := XmlToJson(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(j, j2) {
+		t.Errorf("got %s want %s", j2, j)
+	}
+}